@@ -8,10 +8,25 @@ import (
 	"github.com/qwerius/gonuxt/internal/api"
 	"github.com/gofiber/fiber/v2"
 	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/password"
+	"github.com/qwerius/gonuxt/internal/utils"
 )
 
 func main() {
 	config.Load()
+
+	// keyset JWT dimuat di awal supaya konfigurasi kunci yang salah langsung ketahuan
+	if _, err := utils.CurrentKeySet(); err != nil {
+		log.Fatalf("failed to load JWT keys: %v", err)
+	}
+
+	// corpus password bocor (opsional) dimuat sekali saat startup
+	if n, err := password.LoadBreachedCorpusFromConfig(); err != nil {
+		log.Fatalf("failed to load breached password corpus: %v", err)
+	} else if n > 0 {
+		log.Printf("Loaded %d breached password hashes", n)
+	}
+
     database, err := db.Connect()
     if err != nil {
         log.Fatal(err)
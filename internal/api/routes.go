@@ -2,12 +2,17 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
 	"github.com/qwerius/gonuxt/internal/handler"
 	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/policy"
+	"github.com/qwerius/gonuxt/internal/store"
 )
 
 func RegisterRoutes(app *fiber.App, db *sql.DB) {
@@ -15,6 +20,21 @@ func RegisterRoutes(app *fiber.App, db *sql.DB) {
 	app.Use(middleware.CORS())
 	app.Use(middleware.CSRF())
 
+	// Denylist access token dipakai bersama oleh AuthRequired dan handler
+	store.Revocations = store.NewRevocationStore(db)
+	// API key (personal access token) diterima AuthRequired selain JWT
+	store.APIKeys = store.NewAPIKeyStore(db)
+	// role & permission user (middleware.RequirePermission dkk.) di-cache per instance,
+	// dimuat ulang setelah PRINCIPAL_CACHE_TTL
+	store.Principals = store.NewPrincipalStore(db, config.GetDuration("PRINCIPAL_CACHE_TTL", 30*time.Second))
+	// organisasi aktif request (header X-Org-ID) dipilih oleh middleware.ResolveOrg
+	store.Organizations = store.NewOrganizationStore(db)
+
+	// assignment role sementara yang sudah kadaluarsa dihapus (dan dicatat di audit_logs)
+	// setiap ROLE_EXPIRY_SWEEP_INTERVAL
+	go store.NewUserRoleStore(db).RunExpirySweeper(context.Background(),
+		config.GetDuration("ROLE_EXPIRY_SWEEP_INTERVAL", time.Minute))
+
 	// Handlers
 	userHandler := handler.NewUserHandler(db)
 	authHandler := handler.NewAuthHandler(db)
@@ -24,6 +44,13 @@ func RegisterRoutes(app *fiber.App, db *sql.DB) {
 	oauthHandler := handler.NewOAuthHandler(db)
 	auditHandler := handler.NewAuditHandler(db)
 	captchaHandler := handler.NewCaptchaHandler()
+	sessionHandler := handler.NewSessionHandler(db)
+	mfaHandler := handler.NewMFAHandler(db)
+	apiKeyHandler := handler.NewAPIKeyHandler(db)
+	impersonationHandler := handler.NewImpersonationHandler(db)
+	permissionHandler := handler.NewPermissionHandler(db)
+	loginHistoryHandler := handler.NewLoginHistoryHandler(store.NewLoginEventStore(db))
+	orgHandler := handler.NewOrgHandler(db)
 
 	ipCfg := &middleware.IPFilterConfig{
 		Whitelist: []string{"127.0.0.1", "192.168.1.0/24"},
@@ -41,6 +68,17 @@ func RegisterRoutes(app *fiber.App, db *sql.DB) {
 		})
 	})
 
+	// public key untuk verifikasi JWT oleh layanan lain
+	app.Get("/.well-known/jwks.json", handler.JWKS)
+
+	auditCfg := &middleware.AuditConfig{DB: db}
+
+	// kebijakan akses per resource (ABAC), dari POLICY_FILE atau kebijakan bawaan
+	policies, err := policy.LoadFile(config.Get("POLICY_FILE"))
+	if err != nil {
+		log.Fatalf("RegisterRoutes: %v", err)
+	}
+
 	api := app.Group("/api/v1")
 	users := api.Group("/users", middleware.AuthRequired,
 		middleware.RateLimit(middleware.RateLimitConfig{
@@ -53,52 +91,134 @@ func RegisterRoutes(app *fiber.App, db *sql.DB) {
 		Expiration: time.Minute,
 	})
 
+	// kirim ulang email verifikasi punya limit sendiri, lebih ketat dari authLimit
+	resendLimit := middleware.RateLimit(middleware.RateLimitConfig{
+		Max:        3,
+		Expiration: 15 * time.Minute,
+	})
+
+	// operasi sensitif (ganti email/password, hapus akun, API key, 2FA, akun terhubung)
+	// butuh login / re-autentikasi dalam REAUTH_MAX_AGE terakhir
+	recentAuth := middleware.RequireRecentAuth(config.GetDuration("REAUTH_MAX_AGE", 10*time.Minute))
+
 	api.Get("/captcha", captchaHandler.GenerateCaptcha)
 
 	api.Post("/auth/login", authLimit, authHandler.Login)
 	api.Post("/auth/register", authLimit, authHandler.Register)
 	api.Post("/auth/refresh", authLimit, authHandler.RefreshToken)
+	api.Post("/auth/logout", authHandler.Logout)
+	api.Post("/auth/2fa/verify", authLimit, authHandler.VerifyMFA)
+	api.Post("/auth/reauthenticate", authLimit, middleware.AuthRequired, middleware.RequireSession, authHandler.Reauthenticate)
+	api.Post("/auth/magic-link", authLimit, authHandler.RequestMagicLink)
+	api.Post("/auth/magic-link/consume", authLimit, authHandler.ConsumeMagicLink)
 	api.Post("/auth/forgot-password", authLimit, handler.ForgotPassword(db))
 	api.Post("/auth/reset-password", authLimit, handler.ResetPassword(db))
+	api.Post("/auth/verify-email", authLimit, handler.VerifyEmail(db))
+	api.Post("/auth/resend-verification", resendLimit, handler.ResendVerificationEmail(db))
 
-	api.Get("/oauth/google/login", oauthHandler.GoogleLogin)
-	api.Get("/oauth/google/callback", oauthHandler.GoogleCallback)
+	api.Get("/oauth/providers", oauthHandler.ListProviders)
+	api.Get("/oauth/:provider/login", oauthHandler.Login)
+	api.Get("/oauth/:provider/callback", oauthHandler.Callback)
 
-	users.Get("/", userHandler.GetAllUsers)
-	users.Get("/:id", userHandler.GetUserByID)
-	users.Post("/", middleware.AuthRequired, userHandler.CreateUser)
-	users.Put("/:id", middleware.AuthRequired, userHandler.UpdateUser)
-	users.Delete("/:id", middleware.AuthRequired, userHandler.DeleteUser)
+	users.Get("/", middleware.RequireScope(store.ScopeUsersRead), middleware.RequirePermission(store.PermissionUsersRead), middleware.ResolveOrg, userHandler.GetAllUsers)
+	users.Get("/:id", middleware.RequireScope(store.ScopeUsersRead), middleware.RequirePermission(store.PermissionUsersRead), middleware.ResolveOrg, userHandler.GetUserByID)
+	users.Post("/", middleware.AuthRequired, middleware.RequireScope(store.ScopeUsersWrite), middleware.RequirePermission(store.PermissionUsersWrite), userHandler.CreateUser)
+	// user boleh mengubah akunnya sendiri; akun lain butuh permission users:write (kebijakan "user")
+	users.Put("/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeUsersWrite), middleware.Authorize(policies, "user", "update", middleware.OwnerFromParam("id")), middleware.ResolveOrg, recentAuth, userHandler.UpdateUser)
+	users.Delete("/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeUsersWrite), middleware.RequirePermission(store.PermissionUsersWrite), middleware.ResolveOrg, recentAuth, userHandler.DeleteUser)
 
-	api.Get("/roles", middleware.AuthRequired, middleware.AdminOnly(db), roleHandler.GetAllRoles)
-	api.Get("/roles/:id", middleware.AuthRequired, middleware.AdminOnly(db), roleHandler.GetRoleByID)
-	api.Delete("/roles/:id", middleware.AuthRequired, middleware.AdminOnly(db), roleHandler.DeleteRole)
-	api.Post("/roles", middleware.AuthRequired, middleware.AdminOnly(db), roleHandler.CreateRole)
+	api.Get("/roles", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), roleHandler.GetAllRoles)
+	api.Get("/roles/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), roleHandler.GetRoleByID)
+	api.Delete("/roles/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), roleHandler.DeleteRole)
+	api.Post("/roles", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), roleHandler.CreateRole)
 
-	api.Get("/users/:id/roles", middleware.AuthRequired, middleware.AdminOnly(db), userRoleHandler.GetUserRoles)
-	api.Put("/users/:id/role", middleware.AuthRequired, middleware.AdminOnly(db), userRoleHandler.UpdateUserRole)
-	api.Post("/users/:id/roles", middleware.AuthRequired, middleware.AdminOnly(db), userRoleHandler.AssignRole)
-	api.Delete("/users/:id/roles/:roleId", middleware.AuthRequired, middleware.AdminOnly(db), userRoleHandler.RemoveRole)
-	api.Get("/role", middleware.AuthRequired, middleware.AuthRequired, roleHandler.GetMyRole)
+	api.Put("/roles/:id/parent", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), roleHandler.SetRoleParent)
 
-	api.Get("/profiles/:id", middleware.AuthRequired, middleware.AdminOnly(db), profileHandler.GetProfileByID)
-	api.Get("/profiles", middleware.AuthRequired, profileHandler.GetAllProfiles)
-	api.Get("/profile", middleware.AuthRequired, profileHandler.GetMyProfile)
+	api.Get("/permissions", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), permissionHandler.GetAllPermissions)
+	api.Get("/roles/:id/permissions", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), permissionHandler.GetRolePermissions)
+	api.Post("/roles/:id/permissions", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), permissionHandler.GrantRolePermissions)
+	api.Delete("/roles/:id/permissions/:permission", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), permissionHandler.RevokeRolePermission)
 
-	api.Get("/users/:id/profile", middleware.AuthRequired, profileHandler.GetProfileByUserID)
-	api.Post("/users/:id/profile", middleware.AuthRequired, middleware.OwnerOrAdminMiddleware(), profileHandler.CreateProfileByUserID)
-	api.Put("/users/:id/profile", middleware.AuthRequired, middleware.OwnerOrAdminMiddleware(), profileHandler.UpdateProfileByUserID)
-	api.Delete("/users/:id/profile", middleware.AuthRequired, middleware.OwnerOrAdminMiddleware(), profileHandler.DeleteProfileByUserID)
+	api.Get("/users/:id/permissions/:permission", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), roleHandler.ExplainUserPermission)
+	api.Get("/users/:id/roles", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), userRoleHandler.GetUserRoles)
+	api.Put("/users/:id/role", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), userRoleHandler.UpdateUserRole)
+	api.Post("/users/:id/roles", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), userRoleHandler.AssignRole)
+	api.Delete("/users/:id/roles/:roleId", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesWrite), middleware.RequirePermission(store.PermissionRolesManage), userRoleHandler.RemoveRole)
+	api.Get("/admin/role-expirations", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), middleware.RequirePermission(store.PermissionRolesRead), userRoleHandler.GetRoleExpirations)
+	api.Get("/role", middleware.AuthRequired, middleware.LoadPrincipal, middleware.RequireScope(store.ScopeRolesRead), roleHandler.GetMyRole)
 
-	api.Get("/admin/profile/:id", middleware.AuthRequired, profileHandler.GetProfileByAdmin)
+	api.Get("/me", middleware.AuthRequired, middleware.LoadPrincipal, middleware.RequireScope(store.ScopeUsersRead), userHandler.GetMe)
+	api.Get("/me/permissions/:permission", middleware.AuthRequired, middleware.RequireScope(store.ScopeRolesRead), roleHandler.ExplainMyPermission)
 
-	auditCfg := &middleware.AuditConfig{DB: db}
+	api.Get("/me/sessions", middleware.AuthRequired, middleware.RequireSession, sessionHandler.GetMySessions)
+	api.Delete("/me/sessions", middleware.AuthRequired, middleware.RequireSession, sessionHandler.RevokeMyOtherSessions)
+	api.Delete("/me/sessions/:id", middleware.AuthRequired, middleware.RequireSession, sessionHandler.RevokeMySession)
+
+	api.Get("/me/login-history", middleware.AuthRequired, middleware.RequireSession, loginHistoryHandler.GetMyLoginHistory)
+
+	api.Get("/me/2fa", middleware.AuthRequired, middleware.RequireSession, mfaHandler.GetStatus)
+	api.Post("/me/2fa/setup", middleware.AuthRequired, middleware.RequireSession, recentAuth, mfaHandler.Setup)
+	api.Post("/me/2fa/confirm", middleware.AuthRequired, middleware.RequireSession, recentAuth, mfaHandler.Confirm)
+	api.Post("/me/2fa/disable", middleware.AuthRequired, middleware.RequireSession, recentAuth, mfaHandler.Disable)
+	api.Post("/me/2fa/recovery-codes", middleware.AuthRequired, middleware.RequireSession, recentAuth, mfaHandler.RegenerateRecoveryCodes)
+
+	api.Get("/me/api-keys", middleware.AuthRequired, middleware.RequireSession, apiKeyHandler.GetMyAPIKeys)
+	api.Post("/me/api-keys", middleware.AuthRequired, middleware.RequireSession, recentAuth, apiKeyHandler.CreateMyAPIKey)
+	api.Delete("/me/api-keys/:id", middleware.AuthRequired, middleware.RequireSession, recentAuth, apiKeyHandler.RevokeMyAPIKey)
+
+	api.Get("/me/identities", middleware.AuthRequired, middleware.RequireSession, oauthHandler.GetMyIdentities)
+	api.Post("/me/identities/:provider", middleware.AuthRequired, middleware.RequireSession, recentAuth, oauthHandler.LinkIdentity)
+	api.Delete("/me/identities/:provider", middleware.AuthRequired, middleware.RequireSession, recentAuth, oauthHandler.UnlinkIdentity)
+
+	// impersonasi: butuh permission users:impersonate, dari sesi login sendiri; request selama impersonasi dicatat oleh AuthRequired
+	api.Post("/admin/users/:id/impersonate", middleware.AuthRequired, middleware.RequireSession, middleware.RequirePermission(store.PermissionUsersImpersonate), middleware.AuditLoggingMiddleware(auditCfg), impersonationHandler.StartImpersonation)
+	api.Delete("/me/impersonation", middleware.AuthRequired, middleware.RejectAPIKey, impersonationHandler.StopImpersonation)
+
+	api.Get("/admin/login-events", middleware.AuthRequired, middleware.RequireScope(store.ScopeAuditLogsRead), middleware.RequirePermission(store.PermissionAuditRead), loginHistoryHandler.GetLoginEvents)
+
+	api.Post("/users/:id/unlock", middleware.AuthRequired, middleware.RequireScope(store.ScopeUsersWrite), middleware.RequirePermission(store.PermissionUsersWrite), userHandler.UnlockUser)
+
+	api.Get("/users/:id/sessions", middleware.AuthRequired, middleware.RequireSession, middleware.RequirePermission(store.PermissionUsersRead), sessionHandler.GetUserSessions)
+	api.Delete("/users/:id/sessions", middleware.AuthRequired, middleware.RequireSession, middleware.RequirePermission(store.PermissionUsersWrite), sessionHandler.RevokeUserSessions)
+	api.Delete("/users/:id/sessions/:sessionId", middleware.AuthRequired, middleware.RequireSession, middleware.RequirePermission(store.PermissionUsersWrite), sessionHandler.RevokeUserSession)
+
+	api.Get("/profiles/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesRead), middleware.RequirePermission(store.PermissionProfilesRead), middleware.ResolveOrg, profileHandler.GetProfileByID)
+	api.Get("/profiles", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesRead), middleware.ResolveOrg, profileHandler.GetAllProfiles)
+	api.Get("/profile", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesRead), profileHandler.GetMyProfile)
+
+	api.Get("/users/:id/profile", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesRead), middleware.ResolveOrg, profileHandler.GetProfileByUserID)
+	api.Post("/users/:id/profile", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesWrite), middleware.Authorize(policies, "profile", "create", middleware.OwnerFromParam("id")), profileHandler.CreateProfileByUserID)
+	api.Put("/users/:id/profile", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesWrite), middleware.Authorize(policies, "profile", "update", middleware.OwnerFromParam("id")), profileHandler.UpdateProfileByUserID)
+	api.Delete("/users/:id/profile", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesWrite), middleware.Authorize(policies, "profile", "delete", middleware.OwnerFromParam("id")), profileHandler.DeleteProfileByUserID)
+
+	api.Get("/admin/profile/:id", middleware.AuthRequired, middleware.RequireScope(store.ScopeProfilesRead), middleware.RequirePermission(store.PermissionProfilesRead), middleware.ResolveOrg, profileHandler.GetProfileByAdmin)
 
 	api.Get("/audit-logs",
-		middleware.AuthRequired,                     // pastikan user ada di context
-		middleware.AdminOnly(db),                    // harus admin
-		middleware.AuditLoggingMiddleware(auditCfg), // catat audit log
-		auditHandler.GetAuditLogs,                   // handler untuk menampilkan log
+		middleware.AuthRequired,                                 // pastikan user ada di context
+		middleware.RequireScope(store.ScopeAuditLogsRead),       // API key butuh scope audit_logs:read
+		middleware.RequirePermission(store.PermissionAuditRead), // butuh permission audit:read
+		middleware.ResolveOrg,                                   // hanya log organisasi aktif
+		middleware.AuditLoggingMiddleware(auditCfg),             // catat audit log
+		auditHandler.GetAuditLogs,                               // handler untuk menampilkan log
 	)
 
+	// organisasi: superadmin (orgs:manage) membuat & melihat semua organisasi, owner/admin
+	// organisasi mengelola organisasinya sendiri
+	orgManagers := middleware.RequireOrgRole(store.OrgRoleOwner, store.OrgRoleAdmin)
+	api.Get("/me/orgs", middleware.AuthRequired, middleware.RequireScope(store.ScopeOrgsRead), orgHandler.GetMyOrgs)
+	api.Post("/org-invites/accept", middleware.AuthRequired, middleware.RequireSession, orgHandler.AcceptOrgInvite)
+	api.Get("/orgs", middleware.AuthRequired, middleware.RequireScope(store.ScopeOrgsRead), orgHandler.GetOrgs)
+	api.Post("/orgs", middleware.AuthRequired, middleware.RequireSession, middleware.RequirePermission(store.PermissionOrgsManage), orgHandler.CreateOrg)
+	api.Get("/orgs/:orgId", middleware.AuthRequired, middleware.RequireScope(store.ScopeOrgsRead), middleware.RequireOrgRole(), orgHandler.GetOrg)
+	api.Put("/orgs/:orgId", middleware.AuthRequired, middleware.RequireSession, orgManagers, middleware.AuditLoggingMiddleware(auditCfg), orgHandler.UpdateOrg)
+	api.Delete("/orgs/:orgId", middleware.AuthRequired, middleware.RequireSession, middleware.RequireOrgRole(store.OrgRoleOwner), recentAuth, middleware.AuditLoggingMiddleware(auditCfg), orgHandler.DeleteOrg)
+	api.Get("/orgs/:orgId/members", middleware.AuthRequired, middleware.RequireScope(store.ScopeOrgsRead), middleware.RequireOrgRole(), orgHandler.GetOrgMembers)
+	api.Put("/orgs/:orgId/members/:userId", middleware.AuthRequired, middleware.RequireSession, orgManagers, middleware.AuditLoggingMiddleware(auditCfg), orgHandler.UpdateOrgMemberRole)
+	api.Delete("/orgs/:orgId/members/:userId", middleware.AuthRequired, middleware.RequireSession, middleware.RequireOrgRole(), middleware.AuditLoggingMiddleware(auditCfg), orgHandler.RemoveOrgMember)
+	api.Get("/orgs/:orgId/invites", middleware.AuthRequired, middleware.RequireSession, orgManagers, orgHandler.GetOrgInvites)
+	api.Post("/orgs/:orgId/invites", middleware.AuthRequired, middleware.RequireSession, orgManagers, middleware.AuditLoggingMiddleware(auditCfg), orgHandler.CreateOrgInvite)
+	api.Delete("/orgs/:orgId/invites/:inviteId", middleware.AuthRequired, middleware.RequireSession, orgManagers, middleware.AuditLoggingMiddleware(auditCfg), orgHandler.RevokeOrgInvite)
+	// admin organisasi melihat audit log organisasinya tanpa permission audit:read global
+	api.Get("/orgs/:orgId/audit-logs", middleware.AuthRequired, middleware.RequireScope(store.ScopeAuditLogsRead), orgManagers, auditHandler.GetAuditLogs)
+
 }
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -24,3 +27,41 @@ func Get(key string) string {
 	}
 	return val
 }
+
+// GetList membaca env berisi daftar dipisah koma, mis. "admin,moderator"
+func GetList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(Get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// GetBool membaca env boolean ("true", "1", "yes"); selain itu false
+func GetBool(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(Get(key))) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// GetInt membaca env integer, def dipakai jika kosong atau tidak valid
+func GetInt(key string, def int) int {
+	v, err := strconv.Atoi(strings.TrimSpace(Get(key)))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// GetDuration membaca env durasi Go (mis. "15m", "1h"), def dipakai jika kosong atau tidak valid
+func GetDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(strings.TrimSpace(Get(key)))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,30 @@
+package migrations
+
+// Migration013CreateRefreshTokens membuat tabel refresh_tokens untuk rotasi refresh token
+var Migration013CreateRefreshTokens = Migration{
+	Version: 13,
+	Name:    "create_refresh_tokens",
+	Up: `
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    family_id UUID NOT NULL,
+    device TEXT,
+    ip VARCHAR(45),
+    issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens (user_id);
+`,
+	Down: `
+DROP TABLE IF EXISTS refresh_tokens;
+`,
+}
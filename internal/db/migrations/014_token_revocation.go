@@ -0,0 +1,24 @@
+package migrations
+
+// Migration014TokenRevocation membuat denylist access token (jti / sid) dan
+// watermark per user: token yang diterbitkan sebelum tokens_valid_after ditolak
+var Migration014TokenRevocation = Migration{
+	Version: 14,
+	Name:    "create_revoked_tokens",
+	Up: `
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    token_id TEXT PRIMARY KEY,
+    user_id INT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens (expires_at);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMPTZ;
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_valid_after;
+DROP TABLE IF EXISTS revoked_tokens;
+`,
+}
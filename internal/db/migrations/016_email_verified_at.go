@@ -0,0 +1,17 @@
+package migrations
+
+// Migration016EmailVerifiedAt menambah kolom email_verified_at; user lama dianggap sudah terverifikasi
+var Migration016EmailVerifiedAt = Migration{
+	Version: 16,
+	Name:    "add_email_verified_at_to_users",
+	Up: `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+UPDATE users
+SET email_verified_at = COALESCE(created_at, NOW())
+WHERE email_verified_at IS NULL;
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+`,
+}
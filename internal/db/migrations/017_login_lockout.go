@@ -0,0 +1,17 @@
+package migrations
+
+// Migration017LoginLockout menambah kolom untuk menghitung gagal login per akun dan lockout sementara
+var Migration017LoginLockout = Migration{
+	Version: 17,
+	Name:    "add_login_lockout_to_users",
+	Up: `
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_count INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+`,
+	Down: `
+ALTER TABLE users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS last_failed_login_at;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_login_count;
+`,
+}
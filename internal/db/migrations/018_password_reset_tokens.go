@@ -0,0 +1,25 @@
+package migrations
+
+// Migration018PasswordResetTokens membuat tabel token reset password sekali pakai (disimpan dalam bentuk hash)
+var Migration018PasswordResetTokens = Migration{
+	Version: 18,
+	Name:    "create_password_reset_tokens",
+	Up: `
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens (user_id);
+`,
+	Down: `
+DROP TABLE IF EXISTS password_reset_tokens;
+`,
+}
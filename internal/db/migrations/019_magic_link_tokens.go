@@ -0,0 +1,26 @@
+package migrations
+
+// Migration019MagicLinkTokens membuat tabel token login magic link sekali pakai (disimpan dalam bentuk hash)
+var Migration019MagicLinkTokens = Migration{
+	Version: 19,
+	Name:    "create_magic_link_tokens",
+	Up: `
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    requested_ip TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_user_created ON magic_link_tokens (user_id, created_at);
+`,
+	Down: `
+DROP TABLE IF EXISTS magic_link_tokens;
+`,
+}
@@ -0,0 +1,34 @@
+package migrations
+
+// Migration020APIKeys membuat tabel api_keys (personal access token milik user) dan
+// menambah kolom api_key_id di audit_logs supaya setiap pemakaian key tercatat
+var Migration020APIKeys = Migration{
+	Version: 20,
+	Name:    "create_api_keys",
+	Up: `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    name TEXT NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    secret_hash CHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    last_used_ip TEXT,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS api_key_id INT REFERENCES api_keys(id) ON DELETE SET NULL;
+`,
+	Down: `
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS api_keys;
+`,
+}
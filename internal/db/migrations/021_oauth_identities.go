@@ -0,0 +1,27 @@
+package migrations
+
+// Migration021OAuthIdentities membuat tabel oauth_identities: akun provider (provider + subject)
+// yang terhubung ke user. Satu user maksimal satu akun per provider.
+var Migration021OAuthIdentities = Migration{
+	Version: 21,
+	Name:    "create_oauth_identities",
+	Up: `
+CREATE TABLE IF NOT EXISTS oauth_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ,
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT uq_oauth_identities_provider_subject UNIQUE (provider, subject),
+    CONSTRAINT uq_oauth_identities_user_provider UNIQUE (user_id, provider)
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS oauth_identities;
+`,
+}
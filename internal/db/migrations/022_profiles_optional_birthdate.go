@@ -0,0 +1,14 @@
+package migrations
+
+// Migration022ProfilesOptionalBirthdate tanggal_lahir boleh NULL supaya profil bisa dibuat
+// otomatis dari data provider OAuth; user diminta melengkapinya setelah login.
+var Migration022ProfilesOptionalBirthdate = Migration{
+	Version: 22,
+	Name:    "profiles_optional_tanggal_lahir",
+	Up: `
+ALTER TABLE profiles ALTER COLUMN tanggal_lahir DROP NOT NULL;
+`,
+	Down: `
+ALTER TABLE profiles ALTER COLUMN tanggal_lahir SET NOT NULL;
+`,
+}
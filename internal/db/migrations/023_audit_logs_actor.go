@@ -0,0 +1,16 @@
+package migrations
+
+// Migration023AuditLogsActor menambah actor_user_id di audit_logs: admin yang sebenarnya
+// melakukan request saat meng-impersonate user (user_id berisi user yang di-impersonate)
+var Migration023AuditLogsActor = Migration{
+	Version: 23,
+	Name:    "audit_logs_actor_user_id",
+	Up: `
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_user_id INT REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs (actor_user_id) WHERE actor_user_id IS NOT NULL;
+`,
+	Down: `
+DROP INDEX IF EXISTS idx_audit_logs_actor;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_user_id;
+`,
+}
@@ -0,0 +1,26 @@
+package migrations
+
+// Migration024RefreshTokensAuthTime menyimpan auth_time (kapan user terakhir memasukkan
+// password / faktor kedua) per sesi, supaya claim auth_time tetap benar setelah refresh.
+// Sesi lama memakai waktu token pertama di family-nya.
+var Migration024RefreshTokensAuthTime = Migration{
+	Version: 24,
+	Name:    "refresh_tokens_auth_time",
+	Up: `
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ;
+
+UPDATE refresh_tokens rt SET auth_time = f.first_issued_at
+FROM (
+    SELECT family_id, MIN(issued_at) AS first_issued_at
+    FROM refresh_tokens
+    GROUP BY family_id
+) f
+WHERE rt.family_id = f.family_id AND rt.auth_time IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN auth_time SET DEFAULT NOW();
+ALTER TABLE refresh_tokens ALTER COLUMN auth_time SET NOT NULL;
+`,
+	Down: `
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS auth_time;
+`,
+}
@@ -0,0 +1,29 @@
+package migrations
+
+// Migration025LoginEvents membuat tabel login_events: setiap percobaan login (berhasil maupun
+// gagal) beserta IP, user agent, metode dan alasan gagal. user_id NULL jika email tidak dikenal.
+var Migration025LoginEvents = Migration{
+	Version: 25,
+	Name:    "create_login_events",
+	Up: `
+CREATE TABLE IF NOT EXISTS login_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    method VARCHAR(50) NOT NULL,
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(50),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_events_user ON login_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_events_created ON login_events (created_at DESC);
+-- cek perangkat baru: login sukses sebelumnya dengan IP + user agent yang sama
+CREATE INDEX IF NOT EXISTS idx_login_events_device ON login_events (user_id, ip, user_agent) WHERE success;
+`,
+	Down: `
+DROP TABLE IF EXISTS login_events;
+`,
+}
@@ -0,0 +1,53 @@
+package migrations
+
+// Migration026Permissions membuat tabel permissions dan role_permissions. Daftar permission
+// mengikuti store.PermissionNames; role admin bawaan mendapat semua permission sehingga
+// route yang sebelumnya AdminOnly tetap bisa diakses admin.
+var Migration026Permissions = Migration{
+	Version: 26,
+	Name:    "create_permissions",
+	Up: `
+CREATE TABLE IF NOT EXISTS permissions (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role
+      FOREIGN KEY (role_id) REFERENCES roles(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_permission
+      FOREIGN KEY (permission_id) REFERENCES permissions(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_permissions_permission ON role_permissions (permission_id);
+
+INSERT INTO permissions (name, description)
+VALUES
+  ('users:read', 'Melihat data, sesi dan role user lain'),
+  ('users:write', 'Mengubah user lain: buka kunci akun, cabut sesi'),
+  ('users:impersonate', 'Login sebagai user lain (support)'),
+  ('roles:read', 'Melihat role dan permission'),
+  ('roles:manage', 'Membuat/menghapus role, mengatur role user dan permission role'),
+  ('profiles:read', 'Melihat profil user lain'),
+  ('audit:read', 'Melihat audit log dan riwayat login semua user')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
+`,
+	Down: `
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+`,
+}
@@ -0,0 +1,19 @@
+package migrations
+
+// Migration027RoleHierarchy role boleh mewarisi satu parent role (mis. moderator → pelanggan):
+// user dengan role anak otomatis memiliki role parent beserta permission-nya.
+// Siklus dicegah di store.RoleStore.SetParent; CHECK hanya menolak parent ke diri sendiri.
+var Migration027RoleHierarchy = Migration{
+	Version: 27,
+	Name:    "roles_parent_id",
+	Up: `
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES roles(id) ON DELETE SET NULL;
+ALTER TABLE roles ADD CONSTRAINT roles_parent_not_self CHECK (parent_id IS NULL OR parent_id <> id);
+CREATE INDEX IF NOT EXISTS idx_roles_parent ON roles (parent_id) WHERE parent_id IS NOT NULL;
+`,
+	Down: `
+DROP INDEX IF EXISTS idx_roles_parent;
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_parent_not_self;
+ALTER TABLE roles DROP COLUMN IF EXISTS parent_id;
+`,
+}
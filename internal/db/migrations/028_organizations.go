@@ -0,0 +1,69 @@
+package migrations
+
+// Migration028Organizations multi-tenant: organizations, keanggotaan dengan role per organisasi
+// (owner / admin / member), undangan lewat email, dan org_id di audit_logs. Role global admin
+// tetap menjadi superadmin lintas organisasi dan mendapat permission orgs:manage.
+var Migration028Organizations = Migration{
+	Version: 28,
+	Name:    "create_organizations",
+	Up: `
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id),
+    CONSTRAINT fk_org
+      FOREIGN KEY (org_id) REFERENCES organizations(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_user
+      FOREIGN KEY (user_id) REFERENCES users(id)
+      ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members (user_id);
+
+CREATE TABLE IF NOT EXISTS organization_invites (
+    id SERIAL PRIMARY KEY,
+    org_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'member')),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invites_org ON organization_invites (org_id);
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS org_id INT REFERENCES organizations(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_org ON audit_logs (org_id, created_at DESC) WHERE org_id IS NOT NULL;
+
+INSERT INTO permissions (name, description)
+VALUES ('orgs:manage', 'Membuat, melihat dan mengelola semua organisasi (superadmin)')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin' AND p.name = 'orgs:manage'
+ON CONFLICT DO NOTHING;
+`,
+	Down: `
+DELETE FROM permissions WHERE name = 'orgs:manage';
+DROP INDEX IF EXISTS idx_audit_logs_org;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS organization_invites;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
+`,
+}
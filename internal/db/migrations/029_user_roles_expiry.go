@@ -0,0 +1,19 @@
+package migrations
+
+// Migration029UserRolesExpiry assignment role bisa dibatasi waktu (expires_at) dan mencatat
+// siapa yang memberikannya (granted_by). Assignment yang lewat expires_at tidak berlaku lagi
+// dan dihapus oleh sweeper di latar belakang.
+var Migration029UserRolesExpiry = Migration{
+	Version: 29,
+	Name:    "user_roles_expiry",
+	Up: `
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE user_roles ADD COLUMN IF NOT EXISTS granted_by INT REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_user_roles_expires_at ON user_roles (expires_at) WHERE expires_at IS NOT NULL;
+`,
+	Down: `
+DROP INDEX IF EXISTS idx_user_roles_expires_at;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS granted_by;
+ALTER TABLE user_roles DROP COLUMN IF EXISTS expires_at;
+`,
+}
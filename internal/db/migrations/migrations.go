@@ -20,4 +20,21 @@ var Migrations = []Migration{
 	Migration010Profiles,
 	Migration011UserProfiles,
 	Migration012CreateAuditLogs,
+	Migration013CreateRefreshTokens,
+	Migration014TokenRevocation,
+	Migration015UserMFA,
+	Migration016EmailVerifiedAt,
+	Migration017LoginLockout,
+	Migration018PasswordResetTokens,
+	Migration019MagicLinkTokens,
+	Migration020APIKeys,
+	Migration021OAuthIdentities,
+	Migration022ProfilesOptionalBirthdate,
+	Migration023AuditLogsActor,
+	Migration024RefreshTokensAuthTime,
+	Migration025LoginEvents,
+	Migration026Permissions,
+	Migration027RoleHierarchy,
+	Migration028Organizations,
+	Migration029UserRolesExpiry,
 }
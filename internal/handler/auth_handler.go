@@ -2,26 +2,49 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
 	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/password"
 	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // AuthHandler struct
 type AuthHandler struct {
-	DB *sql.DB
+	DB            *sql.DB
+	RefreshTokens *store.RefreshTokenStore
+	MFA           *store.MFAStore
+	Lockout       *store.LockoutStore
+	MagicLinks    *store.MagicLinkStore
+	LoginEvents   *store.LoginEventStore
 }
 
 // NewAuthHandler constructor
 func NewAuthHandler(db *sql.DB) *AuthHandler {
-	return &AuthHandler{DB: db}
+	return &AuthHandler{
+		DB:            db,
+		RefreshTokens: store.NewRefreshTokenStore(db),
+		MFA:           store.NewMFAStore(db),
+		Lockout:       store.NewLockoutStore(db),
+		MagicLinks:    store.NewMagicLinkStore(db),
+		LoginEvents:   store.NewLoginEventStore(db),
+	}
+}
+
+// refreshTokenMeta mengambil info perangkat dari request untuk disimpan bersama refresh token
+func refreshTokenMeta(c *fiber.Ctx) store.RefreshTokenMeta {
+	return store.RefreshTokenMeta{
+		Device: c.Get(fiber.HeaderUserAgent),
+		IP:     c.IP(),
+	}
 }
 
 // RegisterRequest payload
@@ -64,8 +87,8 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	if body.Email == "" {
 		return utils.Error(c, fiber.StatusBadRequest, "Email is required")
 	}
-	if body.Password == "" {
-		return utils.Error(c, fiber.StatusBadRequest, "Password is required")
+	if errs := checkPasswordPolicy("password", body.Password, body.Email); errs != nil {
+		return passwordPolicyError(c, errs)
 	}
 
 	var exists bool
@@ -77,7 +100,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusConflict, "Email already registered")
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	hashedPassword, err := password.Hash(body.Password)
 	if err != nil {
 		log.Printf("Register: failed to hash password: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create user")
@@ -86,16 +109,24 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var id int
 	err = h.DB.QueryRow(
 		"INSERT INTO users (email, password, created_at) VALUES ($1, $2, $3) RETURNING id",
-		body.Email, string(hashedPassword), time.Now(),
+		body.Email, hashedPassword, time.Now(),
 	).Scan(&id)
 	if err != nil {
 		log.Printf("Register: failed to insert user: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create user")
 	}
 
+	// kirim link verifikasi; kalau gagal user masih bisa minta kirim ulang
+	verificationSent := true
+	if err := sendVerificationEmail(id, body.Email); err != nil {
+		log.Printf("Register: failed to send verification email: %v", err)
+		verificationSent = false
+	}
+
 	return utils.SuccessMessage(c, "User registered successfully", map[string]interface{}{
-		"id":    id,
-		"email": body.Email,
+		"id":                      id,
+		"email":                   body.Email,
+		"verification_email_sent": verificationSent,
 	}, nil)
 }
 
@@ -123,32 +154,181 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusBadRequest, "Password is required")
 	}
 
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
 	var id int
 	var email string
-	var hashedPassword string
-	err := h.DB.QueryRow("SELECT id, email, password FROM users WHERE email=$1", body.Email).Scan(&id, &email, &hashedPassword)
+	var hashedPassword sql.NullString
+	var emailVerifiedAt, lockedUntil sql.NullTime
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT id, email, password, email_verified_at, locked_until FROM users WHERE email=$1", body.Email,
+	).Scan(&id, &email, &hashedPassword, &emailVerifiedAt, &lockedUntil)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// tetap hitung hash supaya waktu respon sama dengan akun yang ada
+			password.SimulateVerify(body.Password)
+			loginFailed(c, h.LoginEvents, 0, body.Email, store.LoginMethodPassword, store.LoginFailureUnknownEmail)
 			return utils.Error(c, fiber.StatusUnauthorized, "Invalid email or password")
 		}
 		log.Printf("Login: failed to query user: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(body.Password)); err != nil {
+	// akun yang sedang dikunci dijawab persis sama dengan password salah,
+	// dan percobaan selama lockout tidak memperpanjang lockout
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		password.SimulateVerify(body.Password)
+		loginFailed(c, h.LoginEvents, id, email, store.LoginMethodPassword, store.LoginFailureAccountLocked)
 		return utils.Error(c, fiber.StatusUnauthorized, "Invalid email or password")
 	}
 
-	accessToken, err := utils.CreateAccessToken(id)
+	var passwordOK, needsRehash bool
+	if hashedPassword.Valid {
+		passwordOK, needsRehash, err = password.Verify(body.Password, hashedPassword.String)
+		if err != nil {
+			log.Printf("Login: failed to verify password for user=%d: %v", id, err)
+		}
+	} else {
+		password.SimulateVerify(body.Password)
+	}
+	if !passwordOK {
+		h.recordLoginFailure(ctx, id, email)
+		loginFailed(c, h.LoginEvents, id, email, store.LoginMethodPassword, store.LoginFailureInvalidPassword)
+		return utils.Error(c, fiber.StatusUnauthorized, "Invalid email or password")
+	}
+
+	// upgrade hash lama (bcrypt / parameter argon2 lama) selagi password plaintext tersedia
+	if needsRehash {
+		h.rehashPassword(ctx, id, body.Password)
+	}
+
+	if !emailVerifiedAt.Valid && config.GetBool("REQUIRE_EMAIL_VERIFICATION") {
+		loginFailed(c, h.LoginEvents, id, email, store.LoginMethodPassword, store.LoginFailureEmailNotVerified)
+		return utils.Error(c, fiber.StatusForbidden, "Email not verified", map[string]string{
+			"code": "EMAIL_NOT_VERIFIED",
+		})
+	}
+
+	return h.challengeOrCompleteLogin(ctx, c, id, email, store.LoginMethodPassword)
+}
+
+// challengeOrCompleteLogin langkah terakhir login (password maupun magic link):
+// user dengan 2FA aktif harus menyelesaikan tantangan MFA dulu, selain itu sesi langsung dibuat.
+// Login dengan 2FA dicatat di login_events saat kode diverifikasi (metode "mfa").
+func (h *AuthHandler) challengeOrCompleteLogin(ctx context.Context, c *fiber.Ctx, id int, email, method string) error {
+	mfaEnabled, err := h.MFA.IsEnabled(ctx, id)
 	if err != nil {
-		log.Printf("Login: failed to create access token: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create access token")
+		log.Printf("Login: failed to check 2FA status: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if mfaEnabled {
+		mfaToken, err := utils.CreateActionToken(id, mfaChallengePurpose, mfaChallengeTTL)
+		if err != nil {
+			log.Printf("Login: failed to create MFA token: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "Failed to create MFA challenge")
+		}
+
+		return utils.SuccessMessage(c, "Two-factor authentication required", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		}, nil)
 	}
 
-	refreshToken, err := utils.CreateRefreshToken(id)
+	return h.completeLogin(c, id, email, method)
+}
+
+// rehashPassword menyimpan ulang hash password dengan hasher & parameter terbaru
+func (h *AuthHandler) rehashPassword(ctx context.Context, id int, plain string) {
+	newHash, err := password.Hash(plain)
 	if err != nil {
-		log.Printf("Login: failed to create refresh token: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create refresh token")
+		log.Printf("Login: failed to rehash password for user=%d: %v", id, err)
+		return
+	}
+	if _, err := h.DB.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", newHash, id); err != nil {
+		log.Printf("Login: failed to store rehashed password for user=%d: %v", id, err)
+	}
+}
+
+// recordLoginFailure mencatat gagal login dan memberi tahu pemilik akun saat akun mulai dikunci
+func (h *AuthHandler) recordLoginFailure(ctx context.Context, id int, email string) {
+	lockedUntil, newlyLocked, err := h.Lockout.RecordFailure(ctx, id)
+	if err != nil {
+		log.Printf("Login: failed to record failed login: %v", err)
+		return
+	}
+	if !newlyLocked {
+		return
+	}
+
+	log.Printf("Login: account user=%d locked until %s", id, lockedUntil.Format(time.RFC3339))
+
+	// kirim di background supaya waktu respon tidak membocorkan status lockout
+	go func() {
+		body := fmt.Sprintf(`
+<p>Hai,</p>
+<p>Kami mendeteksi beberapa kali percobaan login yang gagal ke akun kamu.</p>
+<p>Untuk keamanan, login ke akun ini dikunci sementara sampai %s.</p>
+<p>Jika ini bukan kamu, segera ganti password setelah akun terbuka kembali.</p>
+`, lockedUntil.Format("02 Jan 2006 15:04 MST"))
+
+		if err := utils.SendEmailSMTP(email, "Akun YourApp dikunci sementara", body); err != nil {
+			log.Printf("Login: failed to send lockout email: %v", err)
+		}
+	}()
+}
+
+// completeLogin membuat sesi, mencatat login, dan mengirim response login sukses
+func (h *AuthHandler) completeLogin(c *fiber.Ctx, id int, email, method string) error {
+	if err := startSession(c, h.RefreshTokens, id); err != nil {
+		log.Printf("Login: failed to start session: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create session")
+	}
+	loginSucceeded(c, h.LoginEvents, id, email, method)
+
+	// hitungan gagal login baru direset setelah semua faktor lolos, supaya password
+	// yang benar tidak menghapus hitungan gagal kode 2FA
+	if _, err := h.Lockout.Reset(c.Context(), id); err != nil {
+		log.Printf("Login: failed to reset failed login count: %v", err)
+	}
+
+	enrollmentRequired, err := mfaEnrollmentRequired(c.Context(), h.DB, h.MFA, id)
+	if err != nil {
+		log.Printf("Login: failed to check 2FA policy: %v", err)
+	}
+
+	// Response success (tanpa token di body)
+	return utils.SuccessMessage(c, "Login successful", map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":    id,
+			"email": email,
+		},
+		"mfa_enrollment_required": enrollmentRequired,
+	}, nil)
+}
+
+// startSession membuat sesi baru (refresh token + access token + CSRF token)
+// dan menaruhnya di cookie, dipakai oleh semua jalur login
+func startSession(c *fiber.Ctx, refreshTokens *store.RefreshTokenStore, id int) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	// setiap login membuat sesi (family refresh token) baru
+	authTime := time.Now()
+	refreshToken, sessionID, err := refreshTokens.Issue(ctx, id, authTime, refreshTokenMeta(c))
+	if err != nil {
+		return fmt.Errorf("create refresh token: %w", err)
+	}
+
+	accessToken, err := utils.CreateAccessToken(id, sessionID, authTime)
+	if err != nil {
+		return fmt.Errorf("create access token: %w", err)
+	}
+
+	// Generate CSRF token setelah cookie auth
+	csrfToken, err := middleware.GenerateCSRFToken()
+	if err != nil {
+		return fmt.Errorf("generate CSRF token: %w", err)
 	}
 
 	// Set cookies
@@ -173,13 +353,6 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		Secure:   false,            // Set true jika menggunakan HTTPS di production */
 	})
 
-	// Generate CSRF token setelah cookie auth
-	csrfToken, err := middleware.GenerateCSRFToken()
-	if err != nil {
-		log.Printf("Login: failed to generate CSRF token: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create CSRF token")
-	}
-
 	// Set CSRF token cookie (bisa dibaca frontend)
 	c.Cookie(&fiber.Cookie{
 		Name:     middleware.CSRFCookieName,
@@ -190,13 +363,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		Secure:   false, // true jika production
 	})
 
-	// Response success (tanpa token di body)
-	return utils.SuccessMessage(c, "Login successful", map[string]interface{}{
-		"user": map[string]interface{}{
-			"id":    id,
-			"email": email,
-		},
-	}, nil)
+	return nil
 }
 
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
@@ -205,19 +372,30 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusUnauthorized, "Refresh token missing")
 	}
 
-	userID, err := utils.ValidateRefreshToken(refreshToken)
-	if err != nil {
-		return utils.Error(c, fiber.StatusUnauthorized, "Invalid refresh token")
-	}
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
 
-	accessToken, err := utils.CreateAccessToken(userID)
+	// rotasi: token lama tidak berlaku lagi, token baru di family yang sama
+	rotated, err := h.RefreshTokens.Rotate(ctx, refreshToken, refreshTokenMeta(c))
 	if err != nil {
-		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create access token")
+		if errors.Is(err, store.ErrRefreshTokenReused) {
+			log.Printf("RefreshToken: reuse detected for user=%d ip=%s, token family revoked", rotated.UserID, c.IP())
+			if err := revokeSessionAccess(ctx, rotated.UserID, rotated.FamilyID); err != nil {
+				log.Printf("RefreshToken: failed to revoke access tokens: %v", err)
+			}
+			return utils.Error(c, fiber.StatusUnauthorized, "Refresh token reuse detected, please login again")
+		}
+		if errors.Is(err, store.ErrRefreshTokenInvalid) {
+			return utils.Error(c, fiber.StatusUnauthorized, "Invalid refresh token")
+		}
+		log.Printf("RefreshToken: failed to rotate refresh token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create refresh token")
 	}
 
-	newRefreshToken, err := utils.CreateRefreshToken(userID)
+	// auth_time ikut sesi, bukan waktu refresh
+	accessToken, err := utils.CreateAccessToken(rotated.UserID, rotated.FamilyID, rotated.AuthTime)
 	if err != nil {
-		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create refresh token")
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create access token")
 	}
 
 	/// Generate new CSRF token
@@ -238,7 +416,7 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 
 	c.Cookie(&fiber.Cookie{
 		Name:     "refresh_token",
-		Value:    newRefreshToken,
+		Value:    rotated.Token,
 		HTTPOnly: true,
 		Path:     "/",
 		MaxAge:   7 * 24 * 60 * 60,
@@ -259,6 +437,30 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 
 // Logout digunakan untuk endpoint logout
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	// cabut refresh token di DB supaya tidak bisa dipakai lagi
+	if refreshToken := c.Cookies("refresh_token"); refreshToken != "" {
+		if err := h.RefreshTokens.Revoke(ctx, refreshToken); err != nil {
+			log.Printf("Logout: failed to revoke refresh token: %v", err)
+		}
+	}
+
+	// cabut access token (jti) dan sesinya supaya tidak bisa dipakai sampai exp
+	if claims, err := utils.ParseAccessToken(c.Cookies("access_token")); err == nil {
+		until := time.Now().Add(utils.AccessTokenTTL)
+		if claims.ExpiresAt != nil {
+			until = claims.ExpiresAt.Time
+		}
+		if err := store.Revocations.Revoke(ctx, claims.ID, claims.UserID, until); err != nil {
+			log.Printf("Logout: failed to revoke access token: %v", err)
+		}
+		if err := revokeSessionAccess(ctx, claims.UserID, claims.SessionID); err != nil {
+			log.Printf("Logout: failed to revoke session: %v", err)
+		}
+	}
+
 	// Clear cookies
 	c.Cookie(&fiber.Cookie{
 		Name:     "access_token",
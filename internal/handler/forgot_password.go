@@ -50,10 +50,10 @@ func ForgotPassword(db *sql.DB) fiber.Handler {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "internal server error"})
 		}
 
-		// generate JWT token pakai utils
-		tokenString, err := utils.CreateAccessToken(userID)
+		// token acak sekali pakai; token reset sebelumnya otomatis tidak berlaku
+		tokenString, err := store.NewPasswordResetStore(db).Create(c.Context(), userID)
 		if err != nil {
-			log.Println("JWT generation error:", err)
+			log.Println("Reset token error:", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "internal server error"})
 		}
 
@@ -86,7 +86,7 @@ func sendResetEmailSMTP(to, resetURL string) error {
 <p>Hai,</p>
 <p>Kamu meminta reset password. Klik link berikut untuk mengatur password baru:</p>
 <p><a href="%s">%s</a></p>
-<p>Link ini berlaku 1 jam.</p>
+<p>Link ini berlaku 1 jam dan hanya bisa dipakai sekali.</p>
 `, resetURL, resetURL)
 
 	msg := []byte(
@@ -103,6 +103,7 @@ func sendResetEmailSMTP(to, resetURL string) error {
 		return err
 	}
 
-	log.Printf("Reset password email sent to %s with link: %s\n", to, resetURL)
+	// link berisi token reset, jadi tidak ikut dicatat
+	log.Printf("Reset password email sent to %s\n", to)
 	return nil
 }
@@ -0,0 +1,174 @@
+// Package handler untuk API key (personal access token) milik user
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+type APIKeyHandler struct {
+	DB   *sql.DB
+	Keys *store.APIKeyStore
+}
+
+func NewAPIKeyHandler(db *sql.DB) *APIKeyHandler {
+	return &APIKeyHandler{DB: db, Keys: store.NewAPIKeyStore(db)}
+}
+
+// APIKeyResponse metadata API key; secret tidak pernah ditampilkan lagi setelah dibuat
+type APIKeyResponse struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// CreateAPIKeyRequest payload; expires_at opsional (RFC3339), kosong = tidak kadaluarsa
+type CreateAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// GetMyAPIKeys GET /me/api-keys
+func (h *APIKeyHandler) GetMyAPIKeys(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	keys, err := h.Keys.List(ctx, userID)
+	if err != nil {
+		log.Printf("GetMyAPIKeys: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get API keys")
+	}
+
+	resp := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, apiKeyResponse(&keys[i]))
+	}
+
+	return utils.SuccessMessage(c, "API keys retrieved successfully", resp, map[string]interface{}{
+		"available_scopes": store.APIKeyScopes,
+	})
+}
+
+// CreateMyAPIKey POST /me/api-keys
+func (h *APIKeyHandler) CreateMyAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var body CreateAPIKeyRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "name is required")
+	}
+	if len(body.Scopes) == 0 {
+		return utils.Error(c, fiber.StatusBadRequest, "at least one scope is required", map[string]interface{}{
+			"available_scopes": store.APIKeyScopes,
+		})
+	}
+
+	var expiresAt *time.Time
+	if body.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, body.ExpiresAt)
+		if err != nil {
+			return utils.Error(c, fiber.StatusBadRequest, "expires_at must be an RFC3339 timestamp")
+		}
+		if !t.After(time.Now()) {
+			return utils.Error(c, fiber.StatusBadRequest, "expires_at must be in the future")
+		}
+		expiresAt = &t
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	key, token, err := h.Keys.Create(ctx, userID, body.Name, body.Scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, store.ErrAPIKeyUnknownScope) {
+			return utils.Error(c, fiber.StatusBadRequest, "unknown scope", map[string]interface{}{
+				"available_scopes": store.APIKeyScopes,
+			})
+		}
+		log.Printf("CreateMyAPIKey: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to create API key")
+	}
+
+	return utils.SuccessMessage(c, "API key created. Copy the key now, it will not be shown again", map[string]interface{}{
+		"key":     token,
+		"api_key": apiKeyResponse(key),
+	}, nil)
+}
+
+// RevokeMyAPIKey DELETE /me/api-keys/:id
+func (h *APIKeyHandler) RevokeMyAPIKey(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	keyID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid API key id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := h.Keys.Revoke(ctx, userID, keyID)
+	if err != nil {
+		log.Printf("RevokeMyAPIKey: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke API key")
+	}
+	if !revoked {
+		return utils.Error(c, fiber.StatusNotFound, "API key not found")
+	}
+
+	return utils.SuccessMessage(c, "API key revoked successfully", nil, nil)
+}
+
+func apiKeyResponse(k *store.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     store.APIKeyPrefix + k.Prefix,
+		Scopes:     k.Scopes,
+		LastUsedIP: k.LastUsedIP,
+		CreatedAt:  k.CreatedAt.Format(time.RFC3339),
+	}
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
+	}
+	if k.ExpiresAt != nil {
+		s := k.ExpiresAt.Format(time.RFC3339)
+		resp.ExpiresAt = &s
+	}
+	if k.LastUsedAt != nil {
+		s := k.LastUsedAt.Format(time.RFC3339)
+		resp.LastUsedAt = &s
+	}
+	return resp
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/middleware"
 )
 
 type AuditHandler struct {
@@ -25,17 +26,22 @@ type AuditLogResponse struct {
 	URL       string    `json:"url"`
 	Status    int       `json:"status"`
 	IP        string    `json:"ip"`
+	APIKeyID  any       `json:"api_key_id"`    // terisi jika request memakai API key
+	ActorID   any       `json:"actor_user_id"` // admin yang meng-impersonate user_id
+	OrgID     any       `json:"org_id"`        // organisasi aktif saat request
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// GetAuditLogs GET /audit-logs
+// GetAuditLogs GET /audit-logs dan GET /orgs/:orgId/audit-logs; hanya log organisasi aktif
 func (h *AuditHandler) GetAuditLogs(c *fiber.Ctx) error {
+	scope, scopeArgs := middleware.GetOrgScope(c).OrgCondition("org_id", 1)
 	rows, err := h.DB.Query(`
-		SELECT id, user_id, method, url, status, ip, created_at
+		SELECT id, user_id, method, url, status, ip, api_key_id, actor_user_id, org_id, created_at
 		FROM audit_logs
+		WHERE `+scope+`
 		ORDER BY created_at DESC
 		LIMIT 100
-	`)
+	`, scopeArgs...)
 	if err != nil {
 		log.Printf("GetAuditLogs: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -47,7 +53,7 @@ func (h *AuditHandler) GetAuditLogs(c *fiber.Ctx) error {
 	var logs []AuditLogResponse
 	for rows.Next() {
 		var a AuditLogResponse
-		if err := rows.Scan(&a.ID, &a.UserID, &a.Method, &a.URL, &a.Status, &a.IP, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Method, &a.URL, &a.Status, &a.IP, &a.APIKeyID, &a.ActorID, &a.OrgID, &a.CreatedAt); err != nil {
 			log.Printf("GetAuditLogs scan: %v", err)
 			continue
 		}
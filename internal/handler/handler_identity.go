@@ -0,0 +1,118 @@
+// Package handler untuk akun OAuth yang terhubung ke user (/me/identities)
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// IdentityResponse satu akun provider yang terhubung
+type IdentityResponse struct {
+	Provider    string  `json:"provider"`
+	Email       string  `json:"email"`
+	LinkedAt    string  `json:"linked_at"`
+	LastLoginAt *string `json:"last_login_at"`
+}
+
+// GetMyIdentities GET /me/identities
+func (h *OAuthHandler) GetMyIdentities(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	identities, err := h.Identities.List(ctx, userID)
+	if err != nil {
+		log.Printf("GetMyIdentities: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get linked accounts")
+	}
+
+	resp := make([]IdentityResponse, 0, len(identities))
+	for _, i := range identities {
+		r := IdentityResponse{
+			Provider: i.Provider,
+			Email:    i.Email,
+			LinkedAt: i.CreatedAt.Format(time.RFC3339),
+		}
+		if i.LastLoginAt != nil {
+			s := i.LastLoginAt.Format(time.RFC3339)
+			r.LastLoginAt = &s
+		}
+		resp = append(resp, r)
+	}
+
+	return utils.SuccessMessage(c, "Linked accounts retrieved successfully", resp, map[string]interface{}{
+		"available_providers": h.Providers.Names(),
+	})
+}
+
+// LinkIdentity POST /me/identities/:provider → URL login provider; setelah user login di
+// provider, callback menghubungkan akunnya ke user yang sedang login ini
+func (h *OAuthHandler) LinkIdentity(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	provider, err := h.Providers.Get(c.Params("provider"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusNotFound, "unknown OAuth provider")
+	}
+
+	// setelah callback browser kembali ke return_to (default halaman sukses OAuth)
+	returnTo, err := resolveReturnURL(c.Query("return_to"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "return_to is not an allowed URL")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	authURL, err := beginOAuth(ctx, c, provider, oauthState{
+		LinkUserID: userID,
+		ReturnTo:   returnTo,
+		JSON:       c.Query("mode") == "json",
+	})
+	if err != nil {
+		log.Printf("LinkIdentity %s: %v", provider.Name, err)
+		return utils.Error(c, fiber.StatusBadGateway, "OAuth provider is not available")
+	}
+
+	return utils.SuccessMessage(c, "Continue to the provider to link your account", map[string]string{
+		"authorization_url": authURL,
+	}, nil)
+}
+
+// UnlinkIdentity DELETE /me/identities/:provider
+func (h *OAuthHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	err := h.Identities.Unlink(ctx, userID, c.Params("provider"))
+	switch {
+	case errors.Is(err, store.ErrIdentityNotFound):
+		return utils.Error(c, fiber.StatusNotFound, "linked account not found")
+	case errors.Is(err, store.ErrLastLoginMethod):
+		return utils.Error(c, fiber.StatusConflict,
+			"Cannot unlink the only login method. Set a password or link another provider first")
+	case err != nil:
+		log.Printf("UnlinkIdentity: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to unlink account")
+	}
+
+	return utils.SuccessMessage(c, "Account unlinked successfully", nil, nil)
+}
@@ -0,0 +1,206 @@
+// Package handler untuk impersonasi user oleh admin (support)
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// defaultImpersonationTTL masa berlaku token impersonasi jika IMPERSONATION_TTL kosong
+const defaultImpersonationTTL = 15 * time.Minute
+
+type ImpersonationHandler struct {
+	DB *sql.DB
+}
+
+func NewImpersonationHandler(db *sql.DB) *ImpersonationHandler {
+	return &ImpersonationHandler{DB: db}
+}
+
+// ImpersonationInfo info impersonasi untuk banner di frontend
+type ImpersonationInfo struct {
+	ActorID    int    `json:"actor_id"`
+	ActorEmail string `json:"actor_email"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// StartImpersonation POST /admin/users/:id/impersonate → token berumur pendek untuk user :id
+// dengan claim act berisi admin yang memulai. Token terikat ke sesi admin dan tidak bisa
+// di-refresh; selama dipakai, setiap request dicatat di audit_logs dengan actor_user_id.
+func (h *ImpersonationHandler) StartImpersonation(c *fiber.Ctx) error {
+	actorID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+	claims, ok := c.Locals("token_claims").(*utils.AccessClaims)
+	if !ok || claims.Impersonating() {
+		return utils.Error(c, fiber.StatusForbidden, "impersonation must be started from your own session")
+	}
+
+	targetID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+	if targetID == actorID {
+		return utils.Error(c, fiber.StatusBadRequest, "cannot impersonate yourself")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var email string
+	err = h.DB.QueryRowContext(ctx, "SELECT email FROM users WHERE id = $1", targetID).Scan(&email)
+	if err == sql.ErrNoRows {
+		return utils.Error(c, fiber.StatusNotFound, "user not found")
+	}
+	if err != nil {
+		log.Printf("StartImpersonation: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get user")
+	}
+	actor, err := middleware.GetPrincipal(c)
+	if err != nil {
+		log.Printf("StartImpersonation: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get user")
+	}
+	target, err := store.Principals.Load(ctx, targetID)
+	if err != nil {
+		log.Printf("StartImpersonation: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get user")
+	}
+	if reason := impersonationBlocked(actor, target); reason != "" {
+		return utils.Error(c, fiber.StatusForbidden, reason)
+	}
+
+	ttl := config.GetDuration("IMPERSONATION_TTL", defaultImpersonationTTL)
+	token, impClaims, err := utils.CreateImpersonationToken(targetID, actorID, claims.SessionID, claimsAuthTime(claims), ttl)
+	if err != nil {
+		log.Printf("StartImpersonation: create token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to create impersonation token")
+	}
+
+	log.Printf("[AUDIT] impersonation started actor=%d user=%d jti=%s ip=%s", actorID, targetID, impClaims.ID, c.IP())
+
+	// klien cookie langsung memakai token impersonasi; refresh_token admin tidak diubah
+	if c.Get("Authorization") == "" {
+		setAccessCookie(c, token, int(ttl.Seconds()))
+	}
+
+	return utils.SuccessMessage(c, "Impersonation started", map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_at":   impClaims.ExpiresAt.Time.Format(time.RFC3339),
+		"user": map[string]interface{}{
+			"id":    targetID,
+			"email": email,
+		},
+	}, nil)
+}
+
+// privilegedPermissions permission yang membuat user tidak bisa di-impersonate, walaupun
+// didapat dari role kustom yang tidak mewarisi admin
+var privilegedPermissions = []string{
+	store.PermissionUsersWrite,
+	store.PermissionUsersImpersonate,
+	store.PermissionRolesManage,
+	store.PermissionOrgsManage,
+}
+
+// impersonationBlocked alasan actor tidak boleh meng-impersonate target (kosong = boleh):
+// target admin, punya permission istimewa, atau punya permission yang tidak dimiliki actor
+func impersonationBlocked(actor, target *store.Principal) string {
+	// termasuk role yang mewarisi admin
+	if target.HasRole(store.AdminRole) {
+		return "admins cannot be impersonated"
+	}
+	for _, name := range privilegedPermissions {
+		if target.HasPermission(name) {
+			return "users with permission " + name + " cannot be impersonated"
+		}
+	}
+	for _, name := range target.PermissionNames() {
+		if !actor.HasPermission(name) {
+			return "user has permission " + name + " that you do not have"
+		}
+	}
+	return ""
+}
+
+// StopImpersonation DELETE /me/impersonation → token impersonasi dicabut dan klien cookie
+// mendapat kembali access token admin di sesi yang sama
+func (h *ImpersonationHandler) StopImpersonation(c *fiber.Ctx) error {
+	claims, ok := c.Locals("token_claims").(*utils.AccessClaims)
+	if !ok || !claims.Impersonating() {
+		return utils.Error(c, fiber.StatusBadRequest, "not impersonating")
+	}
+	actorID := claims.Actor.UserID
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := store.Revocations.Revoke(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("StopImpersonation: failed to revoke token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to stop impersonation")
+	}
+
+	log.Printf("[AUDIT] impersonation stopped actor=%d user=%d jti=%s ip=%s", actorID, claims.UserID, claims.ID, c.IP())
+
+	if c.Get("Authorization") == "" {
+		accessToken, err := utils.CreateAccessToken(actorID, claims.SessionID, claimsAuthTime(claims))
+		if err != nil {
+			log.Printf("StopImpersonation: create access token: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "Failed to create access token")
+		}
+		setAccessCookie(c, accessToken, int(utils.AccessTokenTTL.Seconds()))
+	}
+
+	return utils.SuccessMessage(c, "Impersonation stopped", map[string]interface{}{
+		"user": map[string]interface{}{"id": actorID},
+	}, nil)
+}
+
+// impersonationInfo nil jika request tidak memakai token impersonasi
+func impersonationInfo(ctx context.Context, db *sql.DB, c *fiber.Ctx) (*ImpersonationInfo, error) {
+	claims, ok := c.Locals("token_claims").(*utils.AccessClaims)
+	if !ok || !claims.Impersonating() {
+		return nil, nil
+	}
+
+	info := &ImpersonationInfo{
+		ActorID:   claims.Actor.UserID,
+		ExpiresAt: claims.ExpiresAt.Time.Format(time.RFC3339),
+	}
+	err := db.QueryRowContext(ctx, "SELECT email FROM users WHERE id = $1", info.ActorID).Scan(&info.ActorEmail)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return info, nil
+}
+
+// claimsAuthTime auth_time dari token, zero jika tidak ada
+func claimsAuthTime(claims *utils.AccessClaims) time.Time {
+	if claims.AuthTime == nil {
+		return time.Time{}
+	}
+	return claims.AuthTime.Time
+}
+
+// setAccessCookie cookie access_token dengan atribut yang sama seperti RefreshToken
+func setAccessCookie(c *fiber.Ctx, token string, maxAge int) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "access_token",
+		Value:    token,
+		HTTPOnly: true,
+		Path:     "/",
+		MaxAge:   maxAge,
+		SameSite: "Lax",
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+// testPrincipal membuat store.Principal dengan role (langsung) dan permission dari role tersebut
+func testPrincipal(userID int, role string, permissions ...string) *store.Principal {
+	p := &store.Principal{UserID: userID, Permissions: map[string][]store.PermissionGrant{}}
+	if role != "" {
+		p.Roles = []store.EffectiveRole{{Name: role, Path: []string{role}}}
+	}
+	for _, name := range permissions {
+		p.Permissions[name] = []store.PermissionGrant{{}}
+	}
+	return p
+}
+
+func TestImpersonationBlocked(t *testing.T) {
+	support := testPrincipal(1, "support", store.PermissionUsersImpersonate, store.PermissionUsersRead)
+
+	tests := []struct {
+		name    string
+		actor   *store.Principal
+		target  *store.Principal
+		blocked bool
+	}{
+		{"regular user", support, testPrincipal(7, ""), false},
+		{"target with permissions the actor has", support, testPrincipal(7, "viewer", store.PermissionUsersRead), false},
+		{"admin", support, testPrincipal(7, store.AdminRole), true},
+		// role kustom yang tidak mewarisi admin tetapi bisa mengelola role
+		{"custom role with roles:manage", support, testPrincipal(7, "role-manager", store.PermissionRolesManage), true},
+		{"custom role with users:write", support, testPrincipal(7, "helpdesk", store.PermissionUsersWrite), true},
+		{"custom role with orgs:manage", support, testPrincipal(7, "org-admin", store.PermissionOrgsManage), true},
+		{"another impersonator", support, testPrincipal(7, "support", store.PermissionUsersImpersonate), true},
+		{"target with a permission the actor lacks", support, testPrincipal(7, "auditor", store.PermissionAuditRead), true},
+		{
+			"privileged target even for an actor with the same permissions",
+			testPrincipal(1, "super", store.PermissionUsersImpersonate, store.PermissionRolesManage),
+			testPrincipal(7, "role-manager", store.PermissionRolesManage),
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := impersonationBlocked(tt.actor, tt.target)
+			if (reason != "") != tt.blocked {
+				t.Fatalf("impersonationBlocked = %q, want blocked=%v", reason, tt.blocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// JWKS GET /.well-known/jwks.json
+// Format mengikuti RFC 7517 (bukan APIResponse) supaya bisa dibaca library JWT standar.
+func JWKS(c *fiber.Ctx) error {
+	ks, err := utils.CurrentKeySet()
+	if err != nil {
+		log.Printf("JWKS: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "signing keys not available")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(ks.JWKS())
+}
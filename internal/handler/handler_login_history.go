@@ -0,0 +1,185 @@
+// Package handler untuk riwayat login dan notifikasi login dari perangkat baru
+package handler
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+type LoginHistoryHandler struct {
+	Events *store.LoginEventStore
+}
+
+func NewLoginHistoryHandler(events *store.LoginEventStore) *LoginHistoryHandler {
+	return &LoginHistoryHandler{Events: events}
+}
+
+// LoginEventResponse satu percobaan login
+type LoginEventResponse struct {
+	ID            int64  `json:"id"`
+	UserID        *int   `json:"user_id,omitempty"`
+	Email         string `json:"email,omitempty"`
+	IP            string `json:"ip"`
+	UserAgent     string `json:"user_agent"`
+	Method        string `json:"method"`
+	Success       bool   `json:"success"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// GetMyLoginHistory GET /me/login-history → login berhasil & gagal ke akun sendiri
+func (h *LoginHistoryHandler) GetMyLoginHistory(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	return h.list(c, "/me/login-history", store.LoginEventFilter{UserID: userID}, false)
+}
+
+// GetLoginEvents GET /admin/login-events?user_id=&success= (admin), termasuk percobaan
+// dengan email yang tidak terdaftar
+func (h *LoginHistoryHandler) GetLoginEvents(c *fiber.Ctx) error {
+	var f store.LoginEventFilter
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return utils.Error(c, fiber.StatusBadRequest, "invalid user_id")
+		}
+		f.UserID = id
+	}
+	if v := c.Query("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			return utils.Error(c, fiber.StatusBadRequest, "invalid success filter")
+		}
+		f.Success = &success
+	}
+
+	return h.list(c, "/admin/login-events", f, true)
+}
+
+func (h *LoginHistoryHandler) list(c *fiber.Ctx, path string, f store.LoginEventFilter, admin bool) error {
+	pagination := utils.GetPagination(c, 1, 20, 100)
+	f.Limit, f.Offset = pagination.Limit, pagination.Offset
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	events, total, err := h.Events.List(ctx, f)
+	if err != nil {
+		log.Printf("GetLoginHistory: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get login history")
+	}
+
+	resp := make([]LoginEventResponse, 0, len(events))
+	for _, ev := range events {
+		r := LoginEventResponse{
+			ID:            ev.ID,
+			IP:            ev.IP,
+			UserAgent:     ev.UserAgent,
+			Method:        ev.Method,
+			Success:       ev.Success,
+			FailureReason: ev.FailureReason,
+			CreatedAt:     ev.CreatedAt.Format(time.RFC3339),
+		}
+		// user_id & email hanya untuk admin; pemilik akun sudah tahu
+		if admin {
+			if ev.UserID != 0 {
+				id := ev.UserID
+				r.UserID = &id
+			}
+			r.Email = ev.Email
+		}
+		resp = append(resp, r)
+	}
+
+	items, meta := utils.GetPaginatedResponse(resp, total, pagination.Page, pagination.Limit)
+	links := map[string]string{"next": "", "prev": ""}
+	if pagination.Page < meta.TotalPages {
+		links["next"] = fmt.Sprintf("%s?page=%d&limit=%d", path, pagination.Page+1, pagination.Limit)
+	}
+	if pagination.Page > 1 {
+		links["prev"] = fmt.Sprintf("%s?page=%d&limit=%d", path, pagination.Page-1, pagination.Limit)
+	}
+
+	return utils.SuccessMessage(c, "Login history retrieved successfully", items, meta, links)
+}
+
+// loginEvent event login dari request; nilai dari fiber.Ctx di-copy karena
+// dipakai setelah handler selesai
+func loginEvent(c *fiber.Ctx, userID int, email, method string) store.LoginEvent {
+	return store.LoginEvent{
+		UserID:    userID,
+		Email:     strings.Clone(email),
+		IP:        strings.Clone(c.IP()),
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+		Method:    method,
+	}
+}
+
+// loginFailed mencatat percobaan login yang gagal
+func loginFailed(c *fiber.Ctx, events *store.LoginEventStore, userID int, email, method, reason string) {
+	ev := loginEvent(c, userID, email, method)
+	ev.FailureReason = reason
+	recordLoginEvent(events, ev)
+}
+
+// loginSucceeded mencatat login berhasil dan mengirim email jika perangkat/IP belum pernah dipakai
+func loginSucceeded(c *fiber.Ctx, events *store.LoginEventStore, userID int, email, method string) {
+	ev := loginEvent(c, userID, email, method)
+	ev.Success = true
+	recordLoginEvent(events, ev)
+}
+
+// recordLoginEvent disimpan di background supaya tidak menambah waktu respon login
+func recordLoginEvent(events *store.LoginEventStore, ev store.LoginEvent) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		newDevice, err := events.Record(ctx, ev)
+		if err != nil {
+			log.Printf("Login: failed to record login event: %v", err)
+			return
+		}
+		if newDevice && ev.Email != "" {
+			if err := sendNewDeviceEmail(ev); err != nil {
+				log.Printf("Login: failed to send new device email: %v", err)
+			}
+		}
+	}()
+}
+
+// sendNewDeviceEmail memberi tahu pemilik akun ada login dari perangkat/IP baru
+func sendNewDeviceEmail(ev store.LoginEvent) error {
+	device := ev.UserAgent
+	if device == "" {
+		device = "Tidak diketahui"
+	}
+
+	body := fmt.Sprintf(`
+<p>Hai,</p>
+<p>Akun kamu baru saja digunakan untuk login dari perangkat atau lokasi yang belum pernah dipakai sebelumnya.</p>
+<ul>
+<li>Waktu: %s</li>
+<li>IP: %s</li>
+<li>Perangkat: %s</li>
+<li>Metode: %s</li>
+</ul>
+<p>Jika ini kamu, abaikan email ini.</p>
+<p>Jika bukan, segera ganti password dan keluarkan sesi lain dari halaman pengaturan akun.</p>
+`, time.Now().Format("02 Jan 2006 15:04 MST"), html.EscapeString(ev.IP),
+		html.EscapeString(device), html.EscapeString(ev.Method))
+
+	return utils.SendEmailSMTP(ev.Email, "Login baru ke akun YourApp", body)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// MeResponse user yang sedang login
+type MeResponse struct {
+	ID            int      `json:"id"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"` // termasuk role yang diwarisi
+	Permissions   []string `json:"permissions"`
+	// Impersonating true jika request memakai token impersonasi admin; frontend menampilkan banner
+	Impersonating bool               `json:"impersonating"`
+	Impersonation *ImpersonationInfo `json:"impersonation,omitempty"`
+}
+
+// GetMe GET /me
+func (h *UserHandler) GetMe(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	me := MeResponse{ID: userID}
+	var emailVerifiedAt sql.NullTime
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT email, email_verified_at FROM users WHERE id = $1", userID,
+	).Scan(&me.Email, &emailVerifiedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return utils.Error(c, fiber.StatusNotFound, "user not found")
+		}
+		log.Printf("GetMe: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get user")
+	}
+	me.EmailVerified = emailVerifiedAt.Valid
+
+	principal, err := middleware.GetPrincipal(c)
+	if err != nil {
+		log.Printf("GetMe: failed to load roles: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get roles")
+	}
+	me.Roles = principal.RoleNames()
+	me.Permissions = principal.PermissionNames()
+
+	me.Impersonation, err = impersonationInfo(ctx, h.DB, c)
+	if err != nil {
+		log.Printf("GetMe: failed to get impersonation actor: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get user")
+	}
+	me.Impersonating = me.Impersonation != nil
+
+	return utils.SuccessMessage(c, "User retrieved successfully", me, nil)
+}
@@ -0,0 +1,365 @@
+// Package handler untuk two-factor authentication (TOTP)
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"log"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+	"rsc.io/qr"
+)
+
+const (
+	mfaChallengePurpose = "mfa_challenge"
+	mfaChallengeTTL     = 5 * time.Minute
+)
+
+type MFAHandler struct {
+	DB  *sql.DB
+	MFA *store.MFAStore
+}
+
+func NewMFAHandler(db *sql.DB) *MFAHandler {
+	return &MFAHandler{DB: db, MFA: store.NewMFAStore(db)}
+}
+
+// MFACodeRequest payload berisi kode TOTP atau recovery code
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAVerifyRequest payload langkah kedua login
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// GetStatus GET /me/2fa
+func (h *MFAHandler) GetStatus(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	enabled, err := h.MFA.IsEnabled(ctx, userID)
+	if err != nil {
+		log.Printf("GetStatus: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get 2FA status")
+	}
+
+	remaining, err := h.MFA.RemainingRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Printf("GetStatus: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get 2FA status")
+	}
+
+	required, err := mfaEnrollmentRequired(ctx, h.DB, h.MFA, userID)
+	if err != nil {
+		log.Printf("GetStatus: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get 2FA status")
+	}
+
+	return utils.SuccessMessage(c, "2FA status retrieved successfully", map[string]interface{}{
+		"enabled":                  enabled,
+		"enrollment_required":      required,
+		"recovery_codes_remaining": remaining,
+	}, nil)
+}
+
+// Setup POST /me/2fa/setup → secret baru + otpauth URI + QR PNG (data URI)
+func (h *MFAHandler) Setup(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var email string
+	if err := h.DB.QueryRowContext(ctx, "SELECT email FROM users WHERE id = $1", userID).Scan(&email); err != nil {
+		log.Printf("Setup: failed to get user: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to setup 2FA")
+	}
+
+	secret, err := h.MFA.BeginSetup(ctx, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrMFAAlreadyEnabled) {
+			return utils.Error(c, fiber.StatusConflict, "2FA already enabled")
+		}
+		log.Printf("Setup: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to setup 2FA")
+	}
+
+	uri := utils.TOTPURI(totpIssuer(), email, secret)
+
+	qrPNG, err := drawQRCode(uri)
+	if err != nil {
+		log.Printf("Setup: failed to render QR code: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to setup 2FA")
+	}
+
+	return utils.SuccessMessage(c, "Scan the QR code and confirm with a code from your authenticator app", map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrPNG),
+	}, nil)
+}
+
+// Confirm POST /me/2fa/confirm → aktifkan 2FA dan kembalikan recovery code (sekali tampil)
+func (h *MFAHandler) Confirm(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "code is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	codes, err := h.MFA.Confirm(ctx, userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMFANoPendingSetup):
+			return utils.Error(c, fiber.StatusBadRequest, "call /me/2fa/setup first")
+		case errors.Is(err, store.ErrMFAAlreadyEnabled):
+			return utils.Error(c, fiber.StatusConflict, "2FA already enabled")
+		case errors.Is(err, store.ErrMFAInvalidCode):
+			return utils.Error(c, fiber.StatusBadRequest, "invalid code")
+		}
+		log.Printf("Confirm: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to enable 2FA")
+	}
+
+	return utils.SuccessMessage(c, "2FA enabled, store these recovery codes in a safe place", map[string]interface{}{
+		"recovery_codes": codes,
+	}, nil)
+}
+
+// Disable POST /me/2fa/disable → butuh kode TOTP atau recovery code
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		return utils.Error(c, fiber.StatusBadRequest, "code or recovery_code is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	required, err := userHasMFARequiredRole(ctx, h.DB, userID)
+	if err != nil {
+		log.Printf("Disable: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to disable 2FA")
+	}
+	if required {
+		return utils.Error(c, fiber.StatusForbidden, "2FA is required for your role")
+	}
+
+	if err := h.MFA.Verify(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		return mfaVerifyError(c, "Disable", err)
+	}
+
+	if err := h.MFA.Disable(ctx, userID); err != nil {
+		log.Printf("Disable: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to disable 2FA")
+	}
+
+	return utils.SuccessMessage(c, "2FA disabled", nil, nil)
+}
+
+// RegenerateRecoveryCodes POST /me/2fa/recovery-codes → ganti semua recovery code
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var req MFACodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "code is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.MFA.Verify(ctx, userID, req.Code, ""); err != nil {
+		return mfaVerifyError(c, "RegenerateRecoveryCodes", err)
+	}
+
+	codes, err := h.MFA.RegenerateRecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Printf("RegenerateRecoveryCodes: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to regenerate recovery codes")
+	}
+
+	return utils.SuccessMessage(c, "recovery codes regenerated", map[string]interface{}{
+		"recovery_codes": codes,
+	}, nil)
+}
+
+// VerifyMFA POST /auth/2fa/verify → langkah kedua login, set cookie seperti Login
+func (h *AuthHandler) VerifyMFA(c *fiber.Ctx) error {
+	var req MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return utils.Error(c, fiber.StatusBadRequest, "mfa_token and code or recovery_code are required")
+	}
+
+	userID, claims, err := utils.ParseActionToken(req.MFAToken, mfaChallengePurpose)
+	if err != nil {
+		return utils.Error(c, fiber.StatusUnauthorized, "invalid or expired MFA token")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	// token tantangan hanya sekali pakai
+	used, err := store.Revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		log.Printf("VerifyMFA: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to verify code")
+	}
+	if used {
+		return utils.Error(c, fiber.StatusUnauthorized, "invalid or expired MFA token")
+	}
+
+	var email string
+	var lockedUntil sql.NullTime
+	if err := h.DB.QueryRowContext(ctx,
+		"SELECT email, locked_until FROM users WHERE id = $1", userID,
+	).Scan(&email, &lockedUntil); err != nil {
+		log.Printf("VerifyMFA: failed to get user: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	// gagal kode 2FA ikut dihitung ke lockout akun, sehingga mfa_token tidak bisa
+	// dipakai menebak kode; selama dikunci jawabannya sama dengan kode salah
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		loginFailed(c, h.LoginEvents, userID, email, store.LoginMethodMFA, store.LoginFailureAccountLocked)
+		return mfaVerifyError(c, "VerifyMFA", store.ErrMFAInvalidCode)
+	}
+
+	if err := h.MFA.Verify(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, store.ErrMFAInvalidCode) {
+			h.recordLoginFailure(ctx, userID, email)
+			loginFailed(c, h.LoginEvents, userID, email, store.LoginMethodMFA, store.LoginFailureInvalidMFACode)
+		}
+		return mfaVerifyError(c, "VerifyMFA", err)
+	}
+
+	if err := store.Revocations.Revoke(ctx, claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("VerifyMFA: failed to consume MFA token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to verify code")
+	}
+
+	return h.completeLogin(c, userID, email, store.LoginMethodMFA)
+}
+
+func mfaVerifyError(c *fiber.Ctx, op string, err error) error {
+	switch {
+	case errors.Is(err, store.ErrMFAInvalidCode):
+		return utils.Error(c, fiber.StatusUnauthorized, "invalid code")
+	case errors.Is(err, store.ErrMFANotEnabled):
+		return utils.Error(c, fiber.StatusBadRequest, "2FA is not enabled")
+	}
+	log.Printf("%s: %v", op, err)
+	return utils.Error(c, fiber.StatusInternalServerError, "failed to verify code")
+}
+
+// mfaEnrollmentRequired true jika user punya role yang diwajibkan 2FA (MFA_REQUIRED_ROLES)
+// tetapi belum mengaktifkan 2FA
+func mfaEnrollmentRequired(ctx context.Context, db *sql.DB, mfa *store.MFAStore, userID int) (bool, error) {
+	required, err := userHasMFARequiredRole(ctx, db, userID)
+	if err != nil || !required {
+		return false, err
+	}
+
+	enabled, err := mfa.IsEnabled(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return !enabled, nil
+}
+
+func userHasMFARequiredRole(ctx context.Context, db *sql.DB, userID int) (bool, error) {
+	roles := config.GetList("MFA_REQUIRED_ROLES")
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	var has bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.name = ANY($2)
+			  AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+		)
+	`, userID, pq.Array(roles)).Scan(&has)
+	return has, err
+}
+
+func totpIssuer() string {
+	if name := config.Get("APP_NAME"); name != "" {
+		return name
+	}
+	return "MyProject"
+}
+
+// drawQRCode menggambar QR code dengan fogleman/gg dan mengembalikan PNG-nya
+func drawQRCode(text string) ([]byte, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	const scale, quiet = 6, 4 // ukuran modul (px) dan quiet zone (modul)
+	size := (code.Size + 2*quiet) * scale
+
+	dc := gg.NewContext(size, size)
+	dc.SetRGB(1, 1, 1) // background putih
+	dc.Clear()
+
+	dc.SetRGB(0, 0, 0)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				dc.DrawRectangle(float64((x+quiet)*scale), float64((y+quiet)*scale), scale, scale)
+			}
+		}
+	}
+	dc.Fill()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dc.Image()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
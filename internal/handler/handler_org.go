@@ -0,0 +1,544 @@
+// Package handler untuk organisasi (multi-tenant): CRUD, anggota dan undangan
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// orgSlugPattern huruf kecil, angka dan tanda hubung, maksimal 50 karakter (sesuai kolom slug)
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type OrgHandler struct {
+	DB   *sql.DB
+	Orgs *store.OrganizationStore
+}
+
+func NewOrgHandler(db *sql.DB) *OrgHandler {
+	return &OrgHandler{DB: db, Orgs: store.NewOrganizationStore(db)}
+}
+
+type OrgResponse struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Role      string `json:"role,omitempty"` // role user yang meminta di organisasi ini
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type OrgMemberResponse struct {
+	UserID   int    `json:"user_id"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joined_at"`
+}
+
+type OrgInviteResponse struct {
+	ID        int    `json:"id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	InvitedBy int    `json:"invited_by,omitempty"`
+	ExpiresAt string `json:"expires_at"`
+	CreatedAt string `json:"created_at"`
+}
+
+// OrgRequest payload create/update; saat update field kosong tidak diubah
+type OrgRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type OrgMemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type OrgInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptOrgInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// GetOrgs GET /orgs: superadmin melihat semua organisasi, user lain organisasinya sendiri
+func (h *OrgHandler) GetOrgs(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	principal, err := middleware.GetPrincipal(c)
+	if err != nil {
+		log.Printf("GetOrgs: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get organizations")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	orgs, err := h.Orgs.List(ctx, userID, principal.HasPermission(store.PermissionOrgsManage))
+	if err != nil {
+		log.Printf("GetOrgs: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get organizations")
+	}
+
+	return utils.SuccessMessage(c, "organizations retrieved successfully", orgResponses(orgs), nil)
+}
+
+// GetMyOrgs GET /me/orgs: organisasi tempat user menjadi anggota beserta role-nya
+func (h *OrgHandler) GetMyOrgs(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	orgs, err := h.Orgs.List(ctx, userID, false)
+	if err != nil {
+		log.Printf("GetMyOrgs: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get organizations")
+	}
+
+	return utils.SuccessMessage(c, "organizations retrieved successfully", orgResponses(orgs), nil)
+}
+
+// CreateOrg POST /orgs (permission orgs:manage); pembuat menjadi owner pertama
+func (h *OrgHandler) CreateOrg(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var body OrgRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.Slug = strings.ToLower(strings.TrimSpace(body.Slug))
+	if body.Name == "" || body.Slug == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "name and slug are required")
+	}
+	if !validOrgSlug(body.Slug) {
+		return utils.Error(c, fiber.StatusBadRequest, "slug may only contain lowercase letters, digits and dashes (max 50)")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	org, err := h.Orgs.Create(ctx, body.Name, body.Slug, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrOrgSlugTaken) {
+			return utils.Error(c, fiber.StatusConflict, "organization slug already taken")
+		}
+		log.Printf("CreateOrg: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to create organization")
+	}
+
+	return utils.SuccessMessage(c, "organization created successfully", orgResponse(*org), nil)
+}
+
+// GetOrg GET /orgs/:orgId (anggota atau superadmin)
+func (h *OrgHandler) GetOrg(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	org, err := h.Orgs.Get(ctx, scope.OrgID, c.Locals("user_id").(int))
+	if err != nil {
+		if errors.Is(err, store.ErrOrgNotFound) {
+			return utils.Error(c, fiber.StatusNotFound, "organization not found")
+		}
+		log.Printf("GetOrg: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get organization")
+	}
+
+	return utils.SuccessMessage(c, "organization retrieved successfully", orgResponse(*org), nil)
+}
+
+// UpdateOrg PUT /orgs/:orgId (owner/admin organisasi atau superadmin)
+func (h *OrgHandler) UpdateOrg(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	var body OrgRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.Slug = strings.ToLower(strings.TrimSpace(body.Slug))
+	if body.Name == "" && body.Slug == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "nothing to update")
+	}
+	if body.Slug != "" && !validOrgSlug(body.Slug) {
+		return utils.Error(c, fiber.StatusBadRequest, "slug may only contain lowercase letters, digits and dashes (max 50)")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.Orgs.Update(ctx, scope.OrgID, body.Name, body.Slug); err != nil {
+		switch {
+		case errors.Is(err, store.ErrOrgNotFound):
+			return utils.Error(c, fiber.StatusNotFound, "organization not found")
+		case errors.Is(err, store.ErrOrgSlugTaken):
+			return utils.Error(c, fiber.StatusConflict, "organization slug already taken")
+		}
+		log.Printf("UpdateOrg: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to update organization")
+	}
+
+	return h.GetOrg(c)
+}
+
+// DeleteOrg DELETE /orgs/:orgId (owner organisasi atau superadmin)
+func (h *OrgHandler) DeleteOrg(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.Orgs.Delete(ctx, scope.OrgID); err != nil {
+		if errors.Is(err, store.ErrOrgNotFound) {
+			return utils.Error(c, fiber.StatusNotFound, "organization not found")
+		}
+		log.Printf("DeleteOrg: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to delete organization")
+	}
+
+	// organisasi sudah tidak ada, audit log request ini tidak bisa lagi merujuknya (FK)
+	c.Locals("org_id", nil)
+
+	return utils.SuccessMessage(c, "organization deleted successfully", nil, nil)
+}
+
+// GetOrgMembers GET /orgs/:orgId/members
+func (h *OrgHandler) GetOrgMembers(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	members, err := h.Orgs.Members(ctx, scope.OrgID)
+	if err != nil {
+		log.Printf("GetOrgMembers: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get members")
+	}
+
+	resp := make([]OrgMemberResponse, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, OrgMemberResponse{
+			UserID:   m.UserID,
+			Email:    m.Email,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt.Format(time.RFC3339),
+		})
+	}
+
+	return utils.SuccessMessage(c, "members retrieved successfully", resp, nil)
+}
+
+// UpdateOrgMemberRole PUT /orgs/:orgId/members/:userId { "role": "admin" }.
+// Hanya owner (atau superadmin) yang boleh mengubah owner atau menjadikan anggota owner.
+func (h *OrgHandler) UpdateOrgMemberRole(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	memberID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	var body OrgMemberRoleRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	if !store.OrgRoleValid(body.Role) {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role", map[string]interface{}{
+			"allowed_roles": []string{store.OrgRoleOwner, store.OrgRoleAdmin, store.OrgRoleMember},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	current, err := h.Orgs.MemberRole(ctx, scope.OrgID, memberID)
+	if err != nil {
+		if errors.Is(err, store.ErrOrgNotMember) {
+			return utils.Error(c, fiber.StatusNotFound, "member not found")
+		}
+		log.Printf("UpdateOrgMemberRole: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to update member role")
+	}
+	if (current == store.OrgRoleOwner || body.Role == store.OrgRoleOwner) && !actsAsOrgOwner(scope) {
+		return utils.Error(c, fiber.StatusForbidden, "only an owner can change owners")
+	}
+
+	if err := h.Orgs.SetMemberRole(ctx, scope.OrgID, memberID, body.Role); err != nil {
+		switch {
+		case errors.Is(err, store.ErrOrgNotMember):
+			return utils.Error(c, fiber.StatusNotFound, "member not found")
+		case errors.Is(err, store.ErrOrgLastOwner):
+			return utils.Error(c, fiber.StatusConflict, "organization must keep at least one owner")
+		}
+		log.Printf("UpdateOrgMemberRole: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to update member role")
+	}
+
+	return utils.SuccessMessage(c, "member role updated successfully", OrgMemberResponse{UserID: memberID, Role: body.Role}, nil)
+}
+
+// RemoveOrgMember DELETE /orgs/:orgId/members/:userId. Anggota boleh keluar sendiri;
+// mengeluarkan orang lain butuh owner/admin, dan mengeluarkan owner butuh owner.
+func (h *OrgHandler) RemoveOrgMember(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+	userID := c.Locals("user_id").(int)
+
+	memberID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if memberID != userID {
+		if !scope.Superadmin && !store.OrgRoleCanManage(scope.Role) {
+			return utils.Error(c, fiber.StatusForbidden, "Access denied: insufficient organization role")
+		}
+		current, err := h.Orgs.MemberRole(ctx, scope.OrgID, memberID)
+		if err != nil {
+			if errors.Is(err, store.ErrOrgNotMember) {
+				return utils.Error(c, fiber.StatusNotFound, "member not found")
+			}
+			log.Printf("RemoveOrgMember: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to remove member")
+		}
+		if current == store.OrgRoleOwner && !actsAsOrgOwner(scope) {
+			return utils.Error(c, fiber.StatusForbidden, "only an owner can remove owners")
+		}
+	}
+
+	if err := h.Orgs.RemoveMember(ctx, scope.OrgID, memberID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrOrgNotMember):
+			return utils.Error(c, fiber.StatusNotFound, "member not found")
+		case errors.Is(err, store.ErrOrgLastOwner):
+			return utils.Error(c, fiber.StatusConflict, "organization must keep at least one owner")
+		}
+		log.Printf("RemoveOrgMember: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to remove member")
+	}
+
+	return utils.SuccessMessage(c, "member removed successfully", nil, nil)
+}
+
+// GetOrgInvites GET /orgs/:orgId/invites (owner/admin)
+func (h *OrgHandler) GetOrgInvites(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	invites, err := h.Orgs.Invites(ctx, scope.OrgID)
+	if err != nil {
+		log.Printf("GetOrgInvites: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get invitations")
+	}
+
+	resp := make([]OrgInviteResponse, 0, len(invites))
+	for i := range invites {
+		resp = append(resp, orgInviteResponse(&invites[i]))
+	}
+
+	return utils.SuccessMessage(c, "invitations retrieved successfully", resp, nil)
+}
+
+// CreateOrgInvite POST /orgs/:orgId/invites { "email": "...", "role": "member" } (owner/admin).
+// Link undangan dikirim ke email; token tidak dikembalikan di response.
+func (h *OrgHandler) CreateOrgInvite(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+	userID := c.Locals("user_id").(int)
+
+	var body OrgInviteRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	body.Email = strings.TrimSpace(body.Email)
+	if _, err := mail.ParseAddress(body.Email); err != nil || body.Email == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid email")
+	}
+	if body.Role == "" {
+		body.Role = store.OrgRoleMember
+	}
+	if body.Role != store.OrgRoleAdmin && body.Role != store.OrgRoleMember {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role", map[string]interface{}{
+			"allowed_roles": []string{store.OrgRoleAdmin, store.OrgRoleMember},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	org, err := h.Orgs.Get(ctx, scope.OrgID, userID)
+	if err != nil {
+		if errors.Is(err, store.ErrOrgNotFound) {
+			return utils.Error(c, fiber.StatusNotFound, "organization not found")
+		}
+		log.Printf("CreateOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to create invitation")
+	}
+
+	inv, token, err := h.Orgs.CreateInvite(ctx, scope.OrgID, body.Email, body.Role, userID)
+	if err != nil {
+		log.Printf("CreateOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to create invitation")
+	}
+
+	go func(email, orgName, token string, expiresAt time.Time) {
+		if err := sendOrgInviteEmail(email, orgName, token, expiresAt); err != nil {
+			log.Printf("CreateOrgInvite: failed to send email: %v", err)
+		}
+	}(inv.Email, org.Name, token, inv.ExpiresAt)
+
+	return utils.SuccessMessage(c, "invitation sent successfully", orgInviteResponse(inv), nil)
+}
+
+// RevokeOrgInvite DELETE /orgs/:orgId/invites/:inviteId (owner/admin)
+func (h *OrgHandler) RevokeOrgInvite(c *fiber.Ctx) error {
+	scope := middleware.GetOrgScope(c)
+
+	inviteID, err := strconv.Atoi(c.Params("inviteId"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid invitation id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := h.Orgs.RevokeInvite(ctx, scope.OrgID, inviteID)
+	if err != nil {
+		log.Printf("RevokeOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke invitation")
+	}
+	if !revoked {
+		return utils.Error(c, fiber.StatusNotFound, "invitation not found")
+	}
+
+	return utils.SuccessMessage(c, "invitation revoked successfully", nil, nil)
+}
+
+// AcceptOrgInvite POST /org-invites/accept { "token": "..." }; user yang login harus
+// memakai email yang diundang
+func (h *OrgHandler) AcceptOrgInvite(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	var body AcceptOrgInviteRequest
+	if err := c.BodyParser(&body); err != nil || body.Token == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "token is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var email string
+	if err := h.DB.QueryRowContext(ctx, "SELECT email FROM users WHERE id = $1", userID).Scan(&email); err != nil {
+		log.Printf("AcceptOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to accept invitation")
+	}
+
+	inv, err := h.Orgs.AcceptInvite(ctx, body.Token, userID, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrOrgInviteInvalid):
+			return utils.Error(c, fiber.StatusBadRequest, "invalid or expired invitation")
+		case errors.Is(err, store.ErrOrgInviteEmailMismatch):
+			return utils.Error(c, fiber.StatusForbidden, "this invitation was sent to a different email")
+		}
+		log.Printf("AcceptOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to accept invitation")
+	}
+
+	org, err := h.Orgs.Get(ctx, inv.OrgID, userID)
+	if err != nil {
+		log.Printf("AcceptOrgInvite: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to accept invitation")
+	}
+
+	return utils.SuccessMessage(c, "invitation accepted successfully", orgResponse(*org), nil)
+}
+
+// actsAsOrgOwner owner organisasi aktif, atau superadmin
+func actsAsOrgOwner(scope store.OrgScope) bool {
+	return scope.Superadmin || scope.Role == store.OrgRoleOwner
+}
+
+func validOrgSlug(slug string) bool {
+	return len(slug) <= 50 && orgSlugPattern.MatchString(slug)
+}
+
+func orgResponse(o store.Organization) OrgResponse {
+	return OrgResponse{
+		ID:        o.ID,
+		Name:      o.Name,
+		Slug:      o.Slug,
+		Role:      o.Role,
+		CreatedAt: o.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: o.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func orgResponses(orgs []store.Organization) []OrgResponse {
+	resp := make([]OrgResponse, 0, len(orgs))
+	for _, o := range orgs {
+		resp = append(resp, orgResponse(o))
+	}
+	return resp
+}
+
+func orgInviteResponse(inv *store.OrgInvite) OrgInviteResponse {
+	return OrgInviteResponse{
+		ID:        inv.ID,
+		Email:     inv.Email,
+		Role:      inv.Role,
+		InvitedBy: inv.InvitedBy,
+		ExpiresAt: inv.ExpiresAt.Format(time.RFC3339),
+		CreatedAt: inv.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// sendOrgInviteEmail mengirim link undangan organisasi
+func sendOrgInviteEmail(email, orgName, token string, expiresAt time.Time) error {
+	link := fmt.Sprintf("%s/org-invite?token=%s", config.Get("FRONTEND_URL"), token)
+
+	body := fmt.Sprintf(`
+<p>Hai,</p>
+<p>Kamu diundang untuk bergabung dengan organisasi <b>%s</b> di YourApp.</p>
+<p>Klik link berikut untuk menerima undangan (login atau daftar dengan email ini terlebih dahulu):</p>
+<p><a href="%s">Terima undangan</a></p>
+<p>Undangan berlaku sampai %s.</p>
+<p>Jika kamu tidak mengenal organisasi ini, abaikan email ini.</p>
+`, html.EscapeString(orgName), link, expiresAt.Format("02 Jan 2006 15:04 MST"))
+
+	return utils.SendEmailSMTP(email, "Undangan bergabung ke "+orgName, body)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/middleware"
 	"github.com/qwerius/gonuxt/internal/utils"
 )
 
@@ -32,6 +33,8 @@ type ProfileResponse struct {
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 	UserID       int    `json:"user_id"`
+	// MissingFields field wajib yang belum diisi, frontend meminta user melengkapinya
+	MissingFields []string `json:"missing_fields,omitempty"`
 }
 
 // GetProfileByID untuk mendapatkan profile berdasarkan id user.
@@ -47,16 +50,20 @@ func (h *ProfileHandler) GetProfileByID(c *fiber.Ctx) error {
 	var p ProfileResponse
 	var namaBelakang sql.NullString
 	var avatar sql.NullString
+	var tanggalLahir sql.NullString
 	var createdAt, updatedAt sql.NullTime
 
+	// profile user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("up.user_id", 2)
 	err = h.DB.QueryRowContext(ctx, `
 		SELECT p.id, p.nama, p.nama_belakang, p.tanggal_lahir, p.avatar, p.is_verified,
 		       p.created_at, p.updated_at, up.user_id
 		FROM profiles p
 		LEFT JOIN user_profiles up ON up.profile_id = p.id
-		WHERE p.id = $1
-	`, id).Scan(
-		&p.ID, &p.Nama, &namaBelakang, &p.TanggalLahir, &avatar, &p.IsVerified,
+		WHERE p.id = $1 AND `+scope,
+		append([]interface{}{id}, scopeArgs...)...,
+	).Scan(
+		&p.ID, &p.Nama, &namaBelakang, &tanggalLahir, &avatar, &p.IsVerified,
 		&createdAt, &updatedAt, &p.UserID,
 	)
 
@@ -74,6 +81,8 @@ func (h *ProfileHandler) GetProfileByID(c *fiber.Ctx) error {
 	if avatar.Valid {
 		p.Avatar = avatar.String
 	}
+	p.TanggalLahir = tanggalLahir.String
+	p.MissingFields = missingProfileFields(p.Nama, tanggalLahir.Valid)
 	p.CreatedAt = createdAt.Time.Format(time.RFC3339)
 	p.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
 
@@ -94,6 +103,7 @@ func (h *ProfileHandler) GetMyProfile(c *fiber.Ctx) error {
 	var p ProfileResponse
 	var namaBelakang sql.NullString
 	var avatar sql.NullString
+	var tanggalLahir sql.NullString
 	var createdAt, updatedAt sql.NullTime
 
 	err := h.DB.QueryRowContext(ctx, `
@@ -103,7 +113,7 @@ func (h *ProfileHandler) GetMyProfile(c *fiber.Ctx) error {
         JOIN user_profiles up ON up.profile_id = p.id
         WHERE up.user_id = $1
     `, userID).Scan(
-		&p.ID, &p.Nama, &namaBelakang, &p.TanggalLahir, &avatar, &p.IsVerified,
+		&p.ID, &p.Nama, &namaBelakang, &tanggalLahir, &avatar, &p.IsVerified,
 		&createdAt, &updatedAt, &p.UserID,
 	)
 
@@ -121,6 +131,8 @@ func (h *ProfileHandler) GetMyProfile(c *fiber.Ctx) error {
 	if avatar.Valid {
 		p.Avatar = avatar.String
 	}
+	p.TanggalLahir = tanggalLahir.String
+	p.MissingFields = missingProfileFields(p.Nama, tanggalLahir.Valid)
 	p.CreatedAt = createdAt.Time.Format(time.RFC3339)
 	p.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
 
@@ -134,20 +146,29 @@ func (h *ProfileHandler) GetAllProfiles(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
+	// hanya profile user di organisasi aktif (lihat middleware.ResolveOrg)
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("up.user_id", 1)
+
 	var total int
-	if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM profiles").Scan(&total); err != nil {
+	if err := h.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM profiles p
+		LEFT JOIN user_profiles up ON up.profile_id = p.id
+		WHERE `+scope, scopeArgs...).Scan(&total); err != nil {
 		log.Printf("GetAllProfiles: failed to count profiles: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to count profiles")
 	}
 
-	rows, err := h.DB.QueryContext(ctx, `
+	n := len(scopeArgs)
+	rows, err := h.DB.QueryContext(ctx, fmt.Sprintf(`
 		SELECT p.id, p.nama, p.nama_belakang, p.tanggal_lahir, p.avatar, p.is_verified,
 		       p.created_at, p.updated_at, COALESCE(up.user_id, 0) as user_id
 		FROM profiles p
 		LEFT JOIN user_profiles up ON up.profile_id = p.id
+		WHERE %s
 		ORDER BY p.id
-		LIMIT $1 OFFSET $2
-	`, pagination.Limit, pagination.Offset)
+		LIMIT $%d OFFSET $%d
+	`, scope, n+1, n+2), append(scopeArgs, pagination.Limit, pagination.Offset)...)
 
 	if err != nil {
 		log.Printf("GetAllProfiles: failed to query profiles: %v", err)
@@ -161,9 +182,10 @@ func (h *ProfileHandler) GetAllProfiles(c *fiber.Ctx) error {
 		var p ProfileResponse
 		var namaBelakang sql.NullString
 		var avatar sql.NullString
+		var tanggalLahir sql.NullString
 		var createdAt, updatedAt sql.NullTime
 
-		if err := rows.Scan(&p.ID, &p.Nama, &namaBelakang, &p.TanggalLahir, &avatar, &p.IsVerified, &createdAt, &updatedAt, &p.UserID); err != nil {
+		if err := rows.Scan(&p.ID, &p.Nama, &namaBelakang, &tanggalLahir, &avatar, &p.IsVerified, &createdAt, &updatedAt, &p.UserID); err != nil {
 			log.Printf("GetAllProfiles: failed to scan profile: %v", err)
 			return utils.Error(c, fiber.StatusInternalServerError, "failed to scan profile")
 		}
@@ -174,6 +196,8 @@ func (h *ProfileHandler) GetAllProfiles(c *fiber.Ctx) error {
 		if avatar.Valid {
 			p.Avatar = avatar.String
 		}
+		p.TanggalLahir = tanggalLahir.String
+		p.MissingFields = missingProfileFields(p.Nama, tanggalLahir.Valid)
 
 		p.CreatedAt = createdAt.Time.Format(time.RFC3339)
 		p.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
@@ -212,16 +236,20 @@ func (h *ProfileHandler) GetProfileByUserID(c *fiber.Ctx) error {
 	var p ProfileResponse
 	var namaBelakang sql.NullString
 	var avatar sql.NullString
+	var tanggalLahir sql.NullString
 	var createdAt, updatedAt sql.NullTime
 
+	// profile user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("up.user_id", 2)
 	err = h.DB.QueryRowContext(ctx, `
 		SELECT p.id, p.nama, p.nama_belakang, p.tanggal_lahir, p.avatar, p.is_verified,
 		       p.created_at, p.updated_at, up.user_id
 		FROM profiles p
 		JOIN user_profiles up ON up.profile_id = p.id
-		WHERE up.user_id = $1
-	`, userID).Scan(
-		&p.ID, &p.Nama, &namaBelakang, &p.TanggalLahir, &avatar, &p.IsVerified,
+		WHERE up.user_id = $1 AND `+scope,
+		append([]interface{}{userID}, scopeArgs...)...,
+	).Scan(
+		&p.ID, &p.Nama, &namaBelakang, &tanggalLahir, &avatar, &p.IsVerified,
 		&createdAt, &updatedAt, &p.UserID,
 	)
 
@@ -239,6 +267,8 @@ func (h *ProfileHandler) GetProfileByUserID(c *fiber.Ctx) error {
 	if avatar.Valid {
 		p.Avatar = avatar.String
 	}
+	p.TanggalLahir = tanggalLahir.String
+	p.MissingFields = missingProfileFields(p.Nama, tanggalLahir.Valid)
 	p.CreatedAt = createdAt.Time.Format(time.RFC3339)
 	p.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
 
@@ -481,7 +511,7 @@ func (h *ProfileHandler) DeleteProfileByUserID(c *fiber.Ctx) error {
 	return utils.SuccessMessage(c, "Profile deleted successfully", nil, nil, nil)
 }
 
-// GetProfileByAdmin hanya bisa diakses admin, melihat profile user mana pun
+// GetProfileByAdmin melihat profile user mana pun; akses dibatasi permission profiles:read di route
 func (h *ProfileHandler) GetProfileByAdmin(c *fiber.Ctx) error {
 	// ambil param id profile yang ingin dilihat
 	id, err := strconv.Atoi(c.Params("id"))
@@ -489,60 +519,27 @@ func (h *ProfileHandler) GetProfileByAdmin(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusBadRequest, "invalid profile id")
 	}
 
-	// ambil user_id dari context (set oleh middleware Auth)
-	userIDLocal := c.Locals("user_id")
-	var userID int
-	switch v := userIDLocal.(type) {
-	case int:
-		userID = v
-	case int64:
-		userID = int(v)
-	case float64:
-		userID = int(v)
-	case string:
-		userID, err = strconv.Atoi(v)
-		if err != nil {
-			return utils.Error(c, fiber.StatusInternalServerError, "invalid user_id")
-		}
-	default:
-		return utils.Error(c, fiber.StatusInternalServerError, "invalid user_id type")
-	}
-
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	// cek apakah user adalah admin dari tabel roles + user_roles
-	var isAdmin bool
-	err = h.DB.QueryRowContext(ctx, `
-		SELECT EXISTS (
-			SELECT 1 
-			FROM roles r
-			JOIN user_roles ur ON ur.role_id = r.id
-			WHERE ur.user_id = $1 AND r.name = 'admin'
-		)
-	`, userID).Scan(&isAdmin)
-	if err != nil {
-		log.Printf("Check admin role: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "failed to check role")
-	}
-	if !isAdmin {
-		return utils.Error(c, fiber.StatusForbidden, "only admin can access this endpoint")
-	}
-
 	// ambil profile user berdasarkan id
 	var p ProfileResponse
 	var namaBelakang sql.NullString
 	var avatar sql.NullString
+	var tanggalLahir sql.NullString
 	var createdAt, updatedAt sql.NullTime
 
+	// profile user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("up.user_id", 2)
 	err = h.DB.QueryRowContext(ctx, `
 		SELECT p.id, p.nama, p.nama_belakang, p.tanggal_lahir, p.avatar, p.is_verified,
 		       p.created_at, p.updated_at, up.user_id
 		FROM profiles p
 		LEFT JOIN user_profiles up ON up.profile_id = p.id
-		WHERE p.id = $1
-	`, id).Scan(
-		&p.ID, &p.Nama, &namaBelakang, &p.TanggalLahir, &avatar, &p.IsVerified,
+		WHERE p.id = $1 AND `+scope,
+		append([]interface{}{id}, scopeArgs...)...,
+	).Scan(
+		&p.ID, &p.Nama, &namaBelakang, &tanggalLahir, &avatar, &p.IsVerified,
 		&createdAt, &updatedAt, &p.UserID,
 	)
 	if err != nil {
@@ -559,8 +556,22 @@ func (h *ProfileHandler) GetProfileByAdmin(c *fiber.Ctx) error {
 	if avatar.Valid {
 		p.Avatar = avatar.String
 	}
+	p.TanggalLahir = tanggalLahir.String
+	p.MissingFields = missingProfileFields(p.Nama, tanggalLahir.Valid)
 	p.CreatedAt = createdAt.Time.Format(time.RFC3339)
 	p.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
 
 	return utils.SuccessMessage(c, "Profile retrieved successfully", p, nil, nil)
 }
+
+// missingProfileFields field wajib profil (sama dengan validasi CreateProfileByUserID) yang masih kosong
+func missingProfileFields(nama string, hasTanggalLahir bool) []string {
+	missing := []string{}
+	if nama == "" {
+		missing = append(missing, "nama")
+	}
+	if !hasTanggalLahir {
+		missing = append(missing, "tanggal_lahir")
+	}
+	return missing
+}
@@ -0,0 +1,243 @@
+// Package handler untuk manajemen sesi login
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+type SessionHandler struct {
+	DB            *sql.DB
+	RefreshTokens *store.RefreshTokenStore
+}
+
+func NewSessionHandler(db *sql.DB) *SessionHandler {
+	return &SessionHandler{DB: db, RefreshTokens: store.NewRefreshTokenStore(db)}
+}
+
+// SessionResponse satu sesi login aktif
+type SessionResponse struct {
+	ID         string `json:"id"`
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+// GetMySessions GET /me/sessions
+func (h *SessionHandler) GetMySessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+	currentID, _ := c.Locals("session_id").(string)
+
+	return h.listSessions(c, userID, currentID)
+}
+
+// RevokeMySession DELETE /me/sessions/:id
+func (h *SessionHandler) RevokeMySession(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	return h.revokeSession(c, userID, c.Params("id"))
+}
+
+// RevokeMyOtherSessions DELETE /me/sessions → logout di semua perangkat lain
+func (h *SessionHandler) RevokeMyOtherSessions(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	currentID, _ := c.Locals("session_id").(string)
+	if currentID == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "current session is unknown, please login again")
+	}
+
+	return h.revokeOtherSessions(c, userID, currentID)
+}
+
+// GetUserSessions GET /users/:id/sessions (admin)
+func (h *SessionHandler) GetUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	currentID, _ := c.Locals("session_id").(string)
+	return h.listSessions(c, userID, currentID)
+}
+
+// RevokeUserSession DELETE /users/:id/sessions/:sessionId (admin)
+func (h *SessionHandler) RevokeUserSession(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	return h.revokeSession(c, userID, c.Params("sessionId"))
+}
+
+// RevokeUserSessions DELETE /users/:id/sessions (admin) → force-logout user dari semua perangkat
+func (h *SessionHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := logoutEverywhere(ctx, h.DB, userID); err != nil {
+		log.Printf("RevokeUserSessions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	return utils.SuccessMessage(c, "user logged out from all sessions", nil, nil)
+}
+
+func (h *SessionHandler) listSessions(c *fiber.Ctx, userID int, currentID string) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	sessions, err := h.RefreshTokens.ListSessions(ctx, userID)
+	if err != nil {
+		log.Printf("listSessions: failed to query sessions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get sessions")
+	}
+
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{
+			ID:         s.ID,
+			Device:     describeDevice(s.UserAgent),
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+			ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+			Current:    currentID != "" && s.ID == currentID,
+		})
+	}
+
+	return utils.SuccessMessage(c, "sessions retrieved successfully", resp, nil)
+}
+
+func (h *SessionHandler) revokeSession(c *fiber.Ctx, userID int, sessionID string) error {
+	if sessionID == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid session id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	found, err := h.RefreshTokens.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		log.Printf("revokeSession: failed to revoke session: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke session")
+	}
+	if !found {
+		return utils.Error(c, fiber.StatusNotFound, "session not found")
+	}
+
+	if err := revokeSessionAccess(ctx, userID, sessionID); err != nil {
+		log.Printf("revokeSession: failed to revoke access tokens: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke session")
+	}
+
+	return utils.SuccessMessage(c, "session revoked successfully", nil, nil)
+}
+
+func (h *SessionHandler) revokeOtherSessions(c *fiber.Ctx, userID int, exceptID string) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := h.RefreshTokens.RevokeOtherSessions(ctx, userID, exceptID)
+	if err != nil {
+		log.Printf("revokeOtherSessions: failed to revoke sessions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	if err := revokeSessionAccess(ctx, userID, revoked...); err != nil {
+		log.Printf("revokeOtherSessions: failed to revoke access tokens: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke sessions")
+	}
+
+	return utils.SuccessMessage(c, "sessions revoked successfully", map[string]int{"revoked": len(revoked)}, nil)
+}
+
+// revokeSessionAccess memasukkan sesi ke denylist supaya access token yang
+// sudah diterbitkan untuk sesi tersebut langsung ditolak AuthRequired
+func revokeSessionAccess(ctx context.Context, userID int, sessionIDs ...string) error {
+	until := time.Now().Add(utils.AccessTokenTTL)
+	for _, id := range sessionIDs {
+		if err := store.Revocations.Revoke(ctx, id, userID, until); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logoutEverywhere mencabut semua sesi user dan menaikkan watermark token,
+// dipakai setelah ganti password dan force-logout oleh admin
+func logoutEverywhere(ctx context.Context, db *sql.DB, userID int) error {
+	if _, err := store.NewRefreshTokenStore(db).RevokeOtherSessions(ctx, userID, ""); err != nil {
+		return err
+	}
+	return store.Revocations.BumpWatermark(ctx, userID)
+}
+
+// describeDevice membuat label singkat perangkat dari user agent, mis. "Chrome on Windows"
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	os := "Unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+
+	return browser + " on " + os
+}
@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/password"
+	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserHandler struct {
@@ -38,20 +40,25 @@ func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
+	// hanya user di organisasi aktif (lihat middleware.ResolveOrg)
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("id", 1)
+
 	// Hitung total user
 	var total int
-	if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+	if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+scope, scopeArgs...).Scan(&total); err != nil {
 		log.Printf("GetAllUsers: failed to count users: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to count users")
 	}
 
 	// Query data user dengan COALESCE untuk updated_at
+	n := len(scopeArgs)
 	rows, err := h.DB.QueryContext(ctx,
-		`SELECT id, email, created_at, COALESCE(updated_at, created_at) AS updated_at
+		fmt.Sprintf(`SELECT id, email, created_at, COALESCE(updated_at, created_at) AS updated_at
 		 FROM users
+		 WHERE %s
 		 ORDER BY id
-		 LIMIT $1 OFFSET $2`,
-		pagination.Limit, pagination.Offset,
+		 LIMIT $%d OFFSET $%d`, scope, n+1, n+2),
+		append(scopeArgs, pagination.Limit, pagination.Offset)...,
 	)
 	if err != nil {
 		log.Printf("GetAllUsers: failed to query users: %v", err)
@@ -126,11 +133,13 @@ func (h *UserHandler) GetUserByID(c *fiber.Ctx) error {
 	var user UserResponse
 	var createdAt, updatedAt sql.NullTime
 
+	// user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("id", 2)
 	err = h.DB.QueryRowContext(ctx,
 		`SELECT id, email, created_at, COALESCE(updated_at, created_at)
 		 FROM users
-		 WHERE id = $1`,
-		id,
+		 WHERE id = $1 AND `+scope,
+		append([]interface{}{id}, scopeArgs...)...,
 	).Scan(&user.ID, &user.Email, &createdAt, &updatedAt)
 
 	if err != nil {
@@ -159,8 +168,15 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
 	}
 
+	if body.Email == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "email is required")
+	}
+	if errs := checkPasswordPolicy("password", body.Password, body.Email); errs != nil {
+		return passwordPolicyError(c, errs)
+	}
+
 	// Hash password sebelum simpan
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	hashedPassword, err := password.Hash(body.Password)
 	if err != nil {
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to hash password")
 	}
@@ -173,7 +189,7 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		`INSERT INTO users (email, password, created_at)
 		 VALUES ($1, $2, NOW())
 		 RETURNING id`,
-		body.Email, string(hashedPassword),
+		body.Email, hashedPassword,
 	).Scan(&id)
 
 	if err != nil {
@@ -219,17 +235,34 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 
 	if body.Password != nil {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*body.Password), bcrypt.DefaultCost)
+		personal, err := userPersonalInfo(ctx, h.DB, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return utils.Error(c, fiber.StatusNotFound, "user not found")
+			}
+			log.Printf("UpdateUser: failed to load user: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to update user")
+		}
+		if body.Email != nil {
+			personal = append(personal, *body.Email)
+		}
+		if errs := checkPasswordPolicy("password", *body.Password, personal...); errs != nil {
+			return passwordPolicyError(c, errs)
+		}
+
+		hashedPassword, err := password.Hash(*body.Password)
 		if err != nil {
 			return utils.Error(c, fiber.StatusInternalServerError, "failed to hash password")
 		}
 		query += fmt.Sprintf("password = $%d, ", i)
-		args = append(args, string(hashedPassword))
+		args = append(args, hashedPassword)
 		i++
 	}
 
-	query += fmt.Sprintf("updated_at = NOW() WHERE id = $%d", i)
-	args = append(args, id)
+	// user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("id", i+1)
+	query += fmt.Sprintf("updated_at = NOW() WHERE id = $%d AND %s", i, scope)
+	args = append(append(args, id), scopeArgs...)
 
 	res, err := h.DB.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -242,6 +275,14 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusNotFound, "user not found")
 	}
 
+	// ganti password → cabut semua token & sesi user tersebut
+	if body.Password != nil {
+		if err := logoutEverywhere(ctx, h.DB, id); err != nil {
+			log.Printf("UpdateUser: failed to revoke sessions: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+	}
+
 	return utils.SuccessMessage(c, "User updated successfully", nil, nil, nil)
 }
 
@@ -255,7 +296,11 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	res, err := h.DB.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	// user di luar organisasi aktif dianggap tidak ada
+	scope, scopeArgs := middleware.GetOrgScope(c).UserCondition("id", 2)
+	res, err := h.DB.ExecContext(ctx, "DELETE FROM users WHERE id = $1 AND "+scope,
+		append([]interface{}{id}, scopeArgs...)...,
+	)
 	if err != nil {
 		log.Printf("DeleteUser: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to delete user")
@@ -266,5 +311,30 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusNotFound, "user not found")
 	}
 
+	// access token milik user yang dihapus langsung ditolak
+	store.Revocations.Forget(id)
+
 	return utils.SuccessMessage(c, "User deleted successfully", nil, nil, nil)
 }
+
+// UnlockUser POST /users/:id/unlock (admin) → buka lockout akibat gagal login
+func (h *UserHandler) UnlockUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	found, err := store.NewLockoutStore(h.DB).Reset(ctx, id)
+	if err != nil {
+		log.Printf("UnlockUser: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to unlock user")
+	}
+	if !found {
+		return utils.Error(c, fiber.StatusNotFound, "user not found")
+	}
+
+	return utils.SuccessMessage(c, "User unlocked successfully", nil, nil, nil)
+}
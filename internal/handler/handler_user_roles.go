@@ -3,21 +3,24 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
 )
 
 type UserRoleHandler struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Grants *store.UserRoleStore
 }
 
 func NewUserRoleHandler(db *sql.DB) *UserRoleHandler {
-	return &UserRoleHandler{DB: db}
+	return &UserRoleHandler{DB: db, Grants: store.NewUserRoleStore(db)}
 }
 
 type UserRoleResponse struct {
@@ -25,8 +28,30 @@ type UserRoleResponse struct {
 	Roles  []string `json:"roles"`
 }
 
+// AssignRoleRequest payload; expires_at opsional (RFC3339), kosong = permanen
 type AssignRoleRequest struct {
-	RoleID int `json:"role_id"`
+	RoleID    int    `json:"role_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// UserRoleGrantResponse role user beserta masa berlakunya
+type UserRoleGrantResponse struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	GrantedBy *int    `json:"granted_by"`
+	GrantedAt string  `json:"granted_at"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+// RoleExpirationResponse assignment role yang akan kadaluarsa
+type RoleExpirationResponse struct {
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	RoleID    int    `json:"role_id"`
+	Role      string `json:"role"`
+	GrantedBy *int   `json:"granted_by"`
+	GrantedAt string `json:"granted_at"`
+	ExpiresAt string `json:"expires_at"`
 }
 
 // GetUserRoles untuk mendapatkan role berdasarkan user id.
@@ -44,16 +69,17 @@ func (h *UserRoleHandler) GetUserRoles(c *fiber.Ctx) error {
 	// total roles
 	var total int
 	if err := h.DB.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM user_roles WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		`SELECT COUNT(*) FROM user_roles
+		 WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`, userID).Scan(&total); err != nil {
 		log.Printf("GetUserRoles: failed to count roles: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to count roles")
 	}
 
 	rows, err := h.DB.QueryContext(ctx,
-		`SELECT r.id, r.name
+		`SELECT r.id, r.name, ur.granted_by, ur.created_at, ur.expires_at
 		 FROM roles r
 		 JOIN user_roles ur ON ur.role_id = r.id
-		 WHERE ur.user_id = $1
+		 WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
 		 ORDER BY r.id
 		 LIMIT $2 OFFSET $3`,
 		userID, pagination.Limit, pagination.Offset,
@@ -64,13 +90,24 @@ func (h *UserRoleHandler) GetUserRoles(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	roles := []RoleResponse{}
+	roles := []UserRoleGrantResponse{}
 	for rows.Next() {
-		var r RoleResponse
-		if err := rows.Scan(&r.ID, &r.Name); err != nil {
+		var r UserRoleGrantResponse
+		var grantedBy sql.NullInt64
+		var grantedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Name, &grantedBy, &grantedAt, &expiresAt); err != nil {
 			log.Printf("GetUserRoles: failed to scan role: %v", err)
 			return utils.Error(c, fiber.StatusInternalServerError, "failed to scan roles")
 		}
+		if grantedBy.Valid {
+			id := int(grantedBy.Int64)
+			r.GrantedBy = &id
+		}
+		r.GrantedAt = grantedAt.Time.Format(time.RFC3339)
+		if expiresAt.Valid {
+			s := expiresAt.Time.Format(time.RFC3339)
+			r.ExpiresAt = &s
+		}
 		roles = append(roles, r)
 	}
 
@@ -103,6 +140,10 @@ func (h *UserRoleHandler) UpdateUserRole(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
 	}
+	expiresAt, err := roleExpiry(req.ExpiresAt)
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, err.Error())
+	}
 
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
@@ -124,8 +165,8 @@ func (h *UserRoleHandler) UpdateUserRole(c *fiber.Ctx) error {
 
 	// insert new role
 	if _, err := tx.ExecContext(ctx,
-		`INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2)`,
-		userID, req.RoleID,
+		`INSERT INTO user_roles (user_id, role_id, granted_by, expires_at) VALUES ($1, $2, NULLIF($3, 0), $4)`,
+		userID, req.RoleID, roleGrantor(c), expiresAt,
 	); err != nil {
 		tx.Rollback()
 		log.Printf("UpdateUserRole: failed to insert new role: %v", err)
@@ -137,10 +178,14 @@ func (h *UserRoleHandler) UpdateUserRole(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to update role")
 	}
 
+	// role user berubah: principal di cache harus dimuat ulang
+	store.Principals.Invalidate(userID)
+
 	return utils.SuccessMessage(c, "user role updated successfully", nil, nil)
 }
 
-// AssignRole untuk memasukan role berdasarkan user id.
+// AssignRole untuk memasukan role berdasarkan user id. Dengan expires_at role hanya berlaku
+// sampai waktu tersebut; assign ulang role yang sudah dimiliki mengganti masa berlakunya.
 func (h *UserRoleHandler) AssignRole(c *fiber.Ctx) error {
 	userID, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -151,21 +196,21 @@ func (h *UserRoleHandler) AssignRole(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
 	}
+	expiresAt, err := roleExpiry(req.ExpiresAt)
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, err.Error())
+	}
 
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	_, err = h.DB.ExecContext(ctx,
-		`INSERT INTO user_roles (user_id, role_id)
-		 VALUES ($1, $2)
-		 ON CONFLICT (user_id, role_id) DO NOTHING`,
-		userID, req.RoleID,
-	)
-	if err != nil {
+	if err := h.Grants.Assign(ctx, userID, req.RoleID, roleGrantor(c), expiresAt); err != nil {
 		log.Printf("AssignRole: failed to assign role: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to assign role")
 	}
 
+	store.Principals.Invalidate(userID)
+
 	return utils.SuccessMessage(c, "role assigned successfully", nil, nil)
 }
 
@@ -198,6 +243,8 @@ func (h *UserRoleHandler) RemoveRole(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusNotFound, "role not found for this user")
 	}
 
+	store.Principals.Invalidate(userID)
+
 	return utils.SuccessMessage(c, "role removed successfully", nil, nil)
 }
 
@@ -220,7 +267,7 @@ func (h *UserRoleHandler) GetMyRole(c *fiber.Ctx) error {
 		`SELECT r.name
 		 FROM roles r
 		 JOIN user_roles ur ON ur.role_id = r.id
-		 WHERE ur.user_id = $1
+		 WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
 		 ORDER BY r.id`,
 		userID,
 	)
@@ -254,3 +301,76 @@ func (h *UserRoleHandler) GetMyRole(c *fiber.Ctx) error {
 		Roles:  roles,
 	}, nil)
 }
+
+// GetRoleExpirations GET /admin/role-expirations?within=72h: assignment role yang akan
+// kadaluarsa (default dalam 7 hari ke depan)
+func (h *UserRoleHandler) GetRoleExpirations(c *fiber.Ctx) error {
+	within := 7 * 24 * time.Hour
+	if v := c.Query("within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return utils.Error(c, fiber.StatusBadRequest, "within must be a positive duration, e.g. 72h")
+		}
+		within = d
+	}
+
+	pagination := utils.GetPagination(c, 1, 10, 100)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	grants, total, err := h.Grants.Expiring(ctx, within, pagination.Limit, pagination.Offset)
+	if err != nil {
+		log.Printf("GetRoleExpirations: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get role expirations")
+	}
+
+	resp := make([]RoleExpirationResponse, 0, len(grants))
+	for _, g := range grants {
+		resp = append(resp, RoleExpirationResponse{
+			UserID:    g.UserID,
+			Email:     g.Email,
+			RoleID:    g.RoleID,
+			Role:      g.Role,
+			GrantedBy: g.GrantedBy,
+			GrantedAt: g.GrantedAt.Format(time.RFC3339),
+			ExpiresAt: g.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+
+	items, meta := utils.GetPaginatedResponse(resp, total, pagination.Page, pagination.Limit)
+
+	links := map[string]string{"next": "", "prev": ""}
+	if pagination.Page < meta.TotalPages {
+		links["next"] = fmt.Sprintf("/admin/role-expirations?within=%s&page=%d&limit=%d", within, pagination.Page+1, pagination.Limit)
+	}
+	if pagination.Page > 1 {
+		links["prev"] = fmt.Sprintf("/admin/role-expirations?within=%s&page=%d&limit=%d", within, pagination.Page-1, pagination.Limit)
+	}
+
+	return utils.SuccessMessage(c, "role expirations retrieved successfully", items, meta, links)
+}
+
+// roleExpiry membaca expires_at (RFC3339) dari request; kosong berarti permanen
+func roleExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("expires_at must be an RFC3339 timestamp")
+	}
+	if !t.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return &t, nil
+}
+
+// roleGrantor user yang memberi role; saat impersonasi yang dicatat admin yang sebenarnya
+func roleGrantor(c *fiber.Ctx) int {
+	if actorID, ok := c.Locals("actor_id").(int); ok {
+		return actorID
+	}
+	userID, _ := c.Locals("user_id").(int)
+	return userID
+}
@@ -0,0 +1,134 @@
+// Package handler login tanpa password lewat link sekali pakai di email
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// MagicLinkRequest payload
+type MagicLinkRequest struct {
+	Email         string `json:"email"`
+	CaptchaID     string `json:"captcha_id"`
+	CaptchaAnswer string `json:"captcha_answer"`
+}
+
+// RequestMagicLink POST /auth/magic-link
+// Response selalu sama supaya tidak membocorkan email mana yang terdaftar.
+func (h *AuthHandler) RequestMagicLink(c *fiber.Ctx) error {
+	var body MagicLinkRequest
+	if err := c.BodyParser(&body); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "Invalid request body: must be valid JSON")
+	}
+
+	if body.CaptchaID == "" || body.CaptchaAnswer == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "Captcha is required")
+	}
+	if !store.Store.Verify(body.CaptchaID, body.CaptchaAnswer) {
+		return utils.Error(c, fiber.StatusBadRequest, "Captcha salah atau kadaluarsa")
+	}
+
+	if body.Email == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "Email is required")
+	}
+
+	const msg = "If the account exists, a sign-in link has been sent to the email address"
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var id int
+	var email string
+	err := h.DB.QueryRowContext(ctx, "SELECT id, email FROM users WHERE email = $1", body.Email).Scan(&id, &email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.SuccessMessage(c, msg, nil, nil)
+		}
+		log.Printf("RequestMagicLink: failed to query user: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	token, err := h.MagicLinks.Create(ctx, id, c.IP())
+	if err != nil {
+		if errors.Is(err, store.ErrMagicLinkRateLimited) {
+			log.Printf("RequestMagicLink: rate limit reached for user=%d", id)
+			return utils.SuccessMessage(c, msg, nil, nil)
+		}
+		log.Printf("RequestMagicLink: failed to create token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create sign-in link")
+	}
+
+	// kirim di background supaya waktu respon sama untuk email yang tidak terdaftar
+	ttl := h.MagicLinks.TTL
+	go func() {
+		if err := sendMagicLinkEmail(email, token, ttl); err != nil {
+			log.Printf("RequestMagicLink: failed to send email: %v", err)
+		}
+	}()
+
+	return utils.SuccessMessage(c, msg, nil, nil)
+}
+
+// ConsumeMagicLink POST /auth/magic-link/consume { "token": "..." }
+// Setelah token valid, alurnya sama dengan Login: tantangan 2FA atau cookie sesi.
+func (h *AuthHandler) ConsumeMagicLink(c *fiber.Ctx) error {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Token == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "token is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := h.MagicLinks.Consume(ctx, body.Token)
+	if err != nil {
+		if errors.Is(err, store.ErrMagicLinkInvalid) {
+			return utils.Error(c, fiber.StatusUnauthorized, "Invalid or expired sign-in link")
+		}
+		log.Printf("ConsumeMagicLink: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	// link yang sampai ke inbox sekaligus membuktikan kepemilikan email
+	var email string
+	err = h.DB.QueryRowContext(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+		RETURNING email
+	`, id).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.Error(c, fiber.StatusUnauthorized, "Invalid or expired sign-in link")
+		}
+		log.Printf("ConsumeMagicLink: failed to load user: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	return h.challengeOrCompleteLogin(ctx, c, id, email, store.LoginMethodMagicLink)
+}
+
+// sendMagicLinkEmail mengirim link login lewat SMTP
+func sendMagicLinkEmail(email, token string, ttl time.Duration) error {
+	link := fmt.Sprintf("%s/magic-link?token=%s", config.Get("FRONTEND_URL"), token)
+
+	body := fmt.Sprintf(`
+<p>Hai,</p>
+<p>Klik link berikut untuk masuk ke akun kamu tanpa password:</p>
+<p><a href="%s">%s</a></p>
+<p>Link ini berlaku %d menit dan hanya bisa dipakai sekali.
+Jika kamu tidak meminta link ini, abaikan email ini.</p>
+`, link, link, int(ttl.Minutes()))
+
+	return utils.SendEmailSMTP(email, "Link Login YourApp", body)
+}
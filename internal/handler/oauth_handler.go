@@ -3,169 +3,361 @@ package handler
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
-	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/oauth"
+	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
 )
 
 type OAuthHandler struct {
-	DB *sql.DB
+	DB            *sql.DB
+	RefreshTokens *store.RefreshTokenStore
+	Identities    *store.OAuthIdentityStore
+	Providers     *oauth.Registry
+	MFA           *store.MFAStore
+	LoginEvents   *store.LoginEventStore
 }
 
 func NewOAuthHandler(db *sql.DB) *OAuthHandler {
-	return &OAuthHandler{DB: db}
+	providers, err := oauth.ProvidersFromConfig()
+	if err != nil {
+		log.Fatalf("NewOAuthHandler: %v", err)
+	}
+	return &OAuthHandler{
+		DB:            db,
+		RefreshTokens: store.NewRefreshTokenStore(db),
+		Identities:    store.NewOAuthIdentityStore(db),
+		Providers:     providers,
+		MFA:           store.NewMFAStore(db),
+		LoginEvents:   store.NewLoginEventStore(db),
+	}
 }
 
-const (
-	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
-	googleTokenURL = "https://oauth2.googleapis.com/token"
-	googleUserURL  = "https://www.googleapis.com/oauth2/v2/userinfo"
-)
-
-type GoogleTokenResponse struct {
-	AccessToken string `json:"access_token"`
-	IDToken     string `json:"id_token"`
-	ExpiresIn   int    `json:"expires_in"`
+// ListProviders GET /oauth/providers → provider yang bisa dipakai login
+func (h *OAuthHandler) ListProviders(c *fiber.Ctx) error {
+	return utils.SuccessMessage(c, "OAuth providers retrieved successfully", h.Providers.Names(), nil)
 }
 
-type GoogleUserResponse struct {
-	ID            string `json:"id"`
-	Email         string `json:"email"`
-	VerifiedEmail bool   `json:"verified_email"`
-	Name          string `json:"name"`
-	Picture       string `json:"picture"`
-}
+// Login GET /oauth/:provider/login?return_to=/dashboard → redirect ke halaman login provider.
+// ?mode=json untuk client native: callback mengembalikan token di body, bukan cookie + redirect.
+func (h *OAuthHandler) Login(c *fiber.Ctx) error {
+	opts := oauthState{JSON: c.Query("mode") == "json"}
 
-// GoogleLogin untuk mendapakan login dari google
-func (h *OAuthHandler) GoogleLogin(c *fiber.Ctx) error {
-	params := url.Values{}
-	params.Add("client_id", config.Get("GOOGLE_CLIENT_ID"))
-	params.Add("redirect_uri", config.Get("GOOGLE_REDIRECT_URI"))
-	params.Add("response_type", "code")
-	params.Add("scope", "openid email profile")
-	params.Add("access_type", "offline")
-	params.Add("prompt", "consent")
-
-	redirectURL := fmt.Sprintf("%s?%s", googleAuthURL, params.Encode())
+	provider, err := h.Providers.Get(c.Params("provider"))
+	if err != nil {
+		return oauthError(c, &opts, "", oauthErrUnknownProvider)
+	}
+
+	opts.ReturnTo, err = resolveReturnURL(c.Query("return_to"))
+	if err != nil {
+		return oauthError(c, &opts, provider.Name, oauthErrInvalidReturnURL)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	redirectURL, err := beginOAuth(ctx, c, provider, opts)
+	if err != nil {
+		log.Printf("OAuthLogin %s: %v", provider.Name, err)
+		return oauthError(c, &opts, provider.Name, oauthErrUnavailable)
+	}
 	return c.Redirect(redirectURL, fiber.StatusTemporaryRedirect)
 }
 
-// GoogleCallback  mendapatkan callback dari google
-func (h *OAuthHandler) GoogleCallback(c *fiber.Ctx) error {
+// Callback GET /oauth/:provider/callback. Login berhasil membuat sesi cookie yang sama dengan
+// AuthHandler.Login lalu me-redirect ke frontend; kegagalan di-redirect ke halaman error.
+func (h *OAuthHandler) Callback(c *fiber.Ctx) error {
+	provider, err := h.Providers.Get(c.Params("provider"))
+	if err != nil {
+		return oauthError(c, nil, "", oauthErrUnknownProvider)
+	}
+
+	// state harus cocok dengan cookie yang dibuat saat login (anti login CSRF)
+	state, err := finishOAuthState(c, provider)
+	if err != nil {
+		return oauthError(c, nil, provider.Name, oauthErrInvalidState)
+	}
+
+	if e := c.Query("error"); e != "" {
+		log.Printf("OAuthCallback %s: provider returned error=%q", provider.Name, e)
+		return oauthError(c, state, provider.Name, oauthErrAccessDenied)
+	}
+
 	code := c.Query("code")
 	if code == "" {
-		return utils.Error(c, fiber.StatusBadRequest, "code is required")
+		return oauthError(c, state, provider.Name, oauthErrMissingCode)
 	}
 
-	// 1. Exchange code -> token
-	tokenResp, err := exchangeCodeToToken(code)
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Second)
+	defer cancel()
+
+	// 1. Exchange code -> token (dengan PKCE code_verifier)
+	tokenResp, err := provider.Exchange(ctx, code, oauthRedirectURI(c, provider), state.exchangeParams())
 	if err != nil {
-		log.Printf("GoogleCallback token exchange: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "failed to exchange token")
+		log.Printf("OAuthCallback %s token exchange: %v", provider.Name, err)
+		return oauthError(c, state, provider.Name, oauthErrExchange)
 	}
 
-	// 2. Get user info
-	userInfo, err := fetchGoogleUser(tokenResp.AccessToken)
+	// 2. Get user info (ID token + nonce diverifikasi untuk provider OIDC)
+	userInfo, err := provider.FetchUser(ctx, tokenResp, state.Nonce)
 	if err != nil {
-		log.Printf("GoogleCallback fetch user: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "failed to fetch user info")
+		log.Printf("OAuthCallback %s fetch user: %v", provider.Name, err)
+		if errors.Is(err, oauth.ErrNoEmail) {
+			return oauthError(c, state, provider.Name, oauthErrNoEmail)
+		}
+		return oauthError(c, state, provider.Name, oauthErrVerify)
+	}
+
+	// alur link dari /me/identities: hubungkan ke user yang memulai, tanpa membuat sesi baru
+	if state.LinkUserID != 0 {
+		return h.finishLink(ctx, c, state, provider.Name, userInfo)
 	}
 
 	// 3. Register or login
-	userID, err := h.createOrGetUser(userInfo)
+	userID, err := h.createOrGetUser(ctx, provider.Name, userInfo)
+	if err != nil {
+		if errors.Is(err, errOAuthLinkRequired) {
+			loginFailed(c, h.LoginEvents, 0, userInfo.Email, store.LoginMethodOAuth(provider.Name), store.LoginFailureOAuthLinkRequired)
+			return oauthError(c, state, provider.Name, oauthErrLinkRequired)
+		}
+		log.Printf("OAuthCallback %s createOrGetUser: %v", provider.Name, err)
+		return oauthError(c, state, provider.Name, oauthErrServer)
+	}
+
+	var email string
+	var emailVerifiedAt sql.NullTime
+	if err := h.DB.QueryRowContext(ctx,
+		"SELECT email, email_verified_at FROM users WHERE id = $1", userID,
+	).Scan(&email, &emailVerifiedAt); err != nil {
+		log.Printf("OAuthCallback get user: %v", err)
+		return oauthError(c, state, provider.Name, oauthErrServer)
+	}
+	method := store.LoginMethodOAuth(provider.Name)
+	if !emailVerifiedAt.Valid && config.GetBool("REQUIRE_EMAIL_VERIFICATION") {
+		loginFailed(c, h.LoginEvents, userID, email, method, store.LoginFailureEmailNotVerified)
+		return oauthError(c, state, provider.Name, oauthErrEmailNotVerified)
+	}
+
+	// 4. 2FA tetap berlaku untuk login lewat provider
+	mfaEnabled, err := h.MFA.IsEnabled(ctx, userID)
 	if err != nil {
-		log.Printf("GoogleCallback createOrGetUser: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "failed to register/login user")
+		log.Printf("OAuthCallback: failed to check 2FA status: %v", err)
+		return oauthError(c, state, provider.Name, oauthErrServer)
+	}
+	if mfaEnabled {
+		return h.mfaChallenge(c, state, provider.Name, userID)
 	}
 
-	// 4. Generate JWT for your app
-	jwtToken, err := utils.CreateAccessToken(userID)
+	enrollmentRequired, err := mfaEnrollmentRequired(ctx, h.DB, h.MFA, userID)
 	if err != nil {
-		log.Printf("GoogleCallback generate jwt: %v", err)
-		return utils.Error(c, fiber.StatusInternalServerError, "failed to generate token")
+		log.Printf("OAuthCallback: failed to check 2FA policy: %v", err)
 	}
 
-	// 5. Return token to frontend
-	return utils.SuccessMessage(c, "Login successful", map[string]string{
-		"access_token": jwtToken,
-		"token_type":   "Bearer",
-	}, nil, nil)
+	// field profil wajib yang belum ada (mis. tanggal_lahir untuk user baru dari OAuth)
+	missingFields, err := userMissingProfileFields(ctx, h.DB, userID)
+	if err != nil {
+		log.Printf("OAuthCallback: failed to check profile: %v", err)
+	}
+
+	// 5a. client native: token di body
+	if state.JSON {
+		return h.issueTokensJSON(ctx, c, userID, email, method, enrollmentRequired, missingFields)
+	}
+
+	// 5b. browser: sesi cookie seperti login biasa, lalu kembali ke frontend
+	if err := startSession(c, h.RefreshTokens, userID); err != nil {
+		log.Printf("OAuthCallback: failed to start session: %v", err)
+		return oauthError(c, state, provider.Name, oauthErrServer)
+	}
+	loginSucceeded(c, h.LoginEvents, userID, email, method)
+
+	q := url.Values{}
+	if enrollmentRequired {
+		q.Set("mfa_enrollment_required", "true")
+	}
+	if len(missingFields) > 0 {
+		q.Set("complete_profile", strings.Join(missingFields, ","))
+	}
+	target := state.ReturnTo
+	if len(q) > 0 {
+		target = withQuery(target, q)
+	}
+	return c.Redirect(target, fiber.StatusFound)
 }
 
-// Exchange code -> token
-func exchangeCodeToToken(code string) (*GoogleTokenResponse, error) {
-	values := url.Values{}
-	values.Add("code", code)
-	values.Add("client_id", config.Get("GOOGLE_CLIENT_ID"))
-	values.Add("client_secret", config.Get("GOOGLE_CLIENT_SECRET"))
-	values.Add("redirect_uri", config.Get("GOOGLE_REDIRECT_URI"))
-	values.Add("grant_type", "authorization_code")
+// finishLink menghubungkan akun provider ke user yang memulai alur dari /me/identities
+func (h *OAuthHandler) finishLink(ctx context.Context, c *fiber.Ctx, state *oauthState, provider string, info *oauth.UserInfo) error {
+	if err := h.Identities.Link(ctx, state.LinkUserID, provider, info.Subject, info.Email); err != nil {
+		switch {
+		case errors.Is(err, store.ErrIdentityLinkedElsewhere):
+			return oauthError(c, state, provider, oauthErrLinkedElsewhere)
+		case errors.Is(err, store.ErrIdentityProviderLinked):
+			return oauthError(c, state, provider, oauthErrProviderLinked)
+		}
+		log.Printf("OAuthCallback link identity: %v", err)
+		return oauthError(c, state, provider, oauthErrServer)
+	}
 
-	resp, err := http.PostForm(googleTokenURL, values)
+	if state.JSON {
+		return utils.SuccessMessage(c, "Account linked successfully", map[string]string{
+			"provider": provider,
+			"email":    info.Email,
+		}, nil)
+	}
+	return c.Redirect(withQuery(state.ReturnTo, url.Values{"linked": {provider}}), fiber.StatusFound)
+}
+
+// mfaChallenge user dengan 2FA aktif: mfa_token dikirim ke halaman 2FA frontend lewat
+// fragment URL (tidak ikut terkirim ke server / tercatat di log), atau di body untuk mode JSON
+func (h *OAuthHandler) mfaChallenge(c *fiber.Ctx, state *oauthState, provider string, userID int) error {
+	mfaToken, err := utils.CreateActionToken(userID, mfaChallengePurpose, mfaChallengeTTL)
 	if err != nil {
-		return nil, err
+		log.Printf("OAuthCallback: failed to create MFA token: %v", err)
+		return oauthError(c, state, provider, oauthErrServer)
 	}
-	defer resp.Body.Close()
 
-	var tokenResp GoogleTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, err
+	if state.JSON {
+		return utils.SuccessMessage(c, "Two-factor authentication required", map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		}, nil)
 	}
-	return &tokenResp, nil
+
+	target := withQuery(frontendURL(oauthPath("OAUTH_MFA_PATH", "/login/2fa")), url.Values{
+		"return_to": {state.ReturnTo},
+	})
+	return c.Redirect(target+"#"+url.Values{"mfa_token": {mfaToken}}.Encode(), fiber.StatusFound)
 }
 
-// Fetch user info
-func fetchGoogleUser(accessToken string) (*GoogleUserResponse, error) {
-	req, _ := http.NewRequest("GET", googleUserURL, nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+// issueTokensJSON membuat sesi untuk client native dan mengembalikan token di body
+func (h *OAuthHandler) issueTokensJSON(ctx context.Context, c *fiber.Ctx, userID int, email, method string, enrollmentRequired bool, missingFields []string) error {
+	authTime := time.Now()
+	refreshToken, sessionID, err := h.RefreshTokens.Issue(ctx, userID, authTime, refreshTokenMeta(c))
+	if err != nil {
+		log.Printf("OAuthCallback issue refresh token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to generate token")
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	jwtToken, err := utils.CreateAccessToken(userID, sessionID, authTime)
 	if err != nil {
-		return nil, err
+		log.Printf("OAuthCallback generate jwt: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to generate token")
 	}
-	defer resp.Body.Close()
+	loginSucceeded(c, h.LoginEvents, userID, email, method)
+
+	return utils.SuccessMessage(c, "Login successful", map[string]interface{}{
+		"access_token":            jwtToken,
+		"refresh_token":           refreshToken,
+		"token_type":              "Bearer",
+		"mfa_enrollment_required": enrollmentRequired,
+		"missing_profile_fields":  missingFields,
+	}, nil, nil)
+}
 
-	var userResp GoogleUserResponse
-	if err := json.NewDecoder(resp.Body).Decode(&userResp); err != nil {
-		return nil, err
+// oauthRedirectURI <NAME>_REDIRECT_URI, atau callback API ini sendiri jika tidak dikonfigurasi
+func oauthRedirectURI(c *fiber.Ctx, p *oauth.Provider) string {
+	if p.RedirectURI != "" {
+		return p.RedirectURI
 	}
-	return &userResp, nil
+	return c.BaseURL() + "/api/v1/oauth/" + p.Name + "/callback"
 }
 
-// Register or get existing user
-func (h *OAuthHandler) createOrGetUser(user *GoogleUserResponse) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// errOAuthLinkRequired email sudah dipakai akun lain dan tidak aman untuk di-link otomatis
+var errOAuthLinkRequired = errors.New("oauth: account exists, explicit link required")
 
-	var id int
-	err := h.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = $1", user.Email).Scan(&id)
+// createOrGetUser mencari user lewat identity (provider, subject). Jika belum ada:
+//   - email sudah terdaftar → di-link otomatis hanya jika email terverifikasi di provider
+//     DAN di akun lokal; selain itu user harus login lalu link manual (errOAuthLinkRequired)
+//   - email belum terdaftar → user baru dibuat beserta identity dan profil dari data provider
+func (h *OAuthHandler) createOrGetUser(ctx context.Context, provider string, user *oauth.UserInfo) (int, error) {
+	id, err := h.Identities.FindUser(ctx, provider, user.Subject)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, store.ErrIdentityNotFound) {
+		return 0, err
+	}
+
+	var localVerified sql.NullTime
+	err = h.DB.QueryRowContext(ctx,
+		"SELECT id, email_verified_at FROM users WHERE email = $1", user.Email,
+	).Scan(&id, &localVerified)
 	if err != nil && err != sql.ErrNoRows {
 		return 0, err
 	}
 
 	// user already exists
 	if id != 0 {
+		if !user.EmailVerified || !localVerified.Valid {
+			return 0, errOAuthLinkRequired
+		}
+		if err := h.Identities.Link(ctx, id, provider, user.Subject, user.Email); err != nil {
+			if errors.Is(err, store.ErrIdentityProviderLinked) {
+				// user sudah link akun lain dari provider yang sama
+				return 0, errOAuthLinkRequired
+			}
+			return 0, err
+		}
 		return id, nil
 	}
 
-	// create new user
-	err = h.DB.QueryRowContext(ctx, `
-		INSERT INTO users (email, created_at, updated_at)
-		VALUES ($1, NOW(), NOW())
-		RETURNING id
-	`, user.Email).Scan(&id)
+	// create new user; email yang sudah diverifikasi provider langsung dianggap terverifikasi
+	var verifiedAt *time.Time
+	if user.EmailVerified {
+		now := time.Now()
+		verifiedAt = &now
+	}
+
+	// foto profil diunduh di luar transaksi; gagal unduh tidak menggagalkan sign-up
+	var avatarPath string
+	if user.Picture != "" {
+		if avatarPath, err = downloadAvatar(ctx, user.Picture, provider); err != nil {
+			log.Printf("OAuthCallback %s download avatar: %v", provider, err)
+		}
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			removeAvatar(avatarPath)
+		}
+	}()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (email, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING id
+	`, user.Email, verifiedAt).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
 
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO oauth_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, id, provider, user.Subject, user.Email); err != nil {
+		return 0, err
+	}
+
+	if err := createOAuthProfile(ctx, tx, id, user, avatarPath); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	committed = true
 	return id, nil
 }
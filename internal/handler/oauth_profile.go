@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/oauth"
+)
+
+const (
+	// avatarDir dan avatarURLPrefix sama dengan upload avatar di ProfileHandler
+	avatarDir       = "./media/avatars"
+	avatarURLPrefix = "/media/avatars/"
+	maxAvatarBytes  = 5 << 20
+)
+
+var avatarClient = &http.Client{Timeout: 10 * time.Second}
+
+// avatarHosts host foto profil per provider; awalan "." berarti semua subdomain.
+// Provider lain diatur lewat <NAME>_AVATAR_HOSTS, tanpa itu fotonya tidak diunduh.
+var avatarHosts = map[string][]string{
+	"google": {".googleusercontent.com"},
+	"github": {"avatars.githubusercontent.com"},
+}
+
+// avatarExtensions tipe gambar yang diterima, berdasarkan isi file (bukan header dari server)
+var avatarExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// downloadAvatar mengunduh foto profil dari provider ke media/avatars dan mengembalikan
+// path publiknya. Hanya URL https ke host di avatarHosts yang diikuti, termasuk saat redirect.
+func downloadAvatar(ctx context.Context, rawURL, provider string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !avatarURLAllowed(u, provider) {
+		return "", fmt.Errorf("avatar url %q not allowed", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	client := *avatarClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 || !avatarURLAllowed(req.URL, provider) {
+			return fmt.Errorf("avatar redirect to %q not allowed", req.URL)
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("avatar download: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAvatarBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxAvatarBytes {
+		return "", errors.New("avatar download: file too large")
+	}
+	ext, ok := avatarExtensions[http.DetectContentType(data)]
+	if !ok {
+		return "", errors.New("avatar download: not an image")
+	}
+
+	if err := os.MkdirAll(avatarDir, 0o755); err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("%d_%s%s", time.Now().UnixNano(), provider, ext)
+	if err := os.WriteFile(filepath.Join(avatarDir, filename), data, 0o644); err != nil {
+		return "", err
+	}
+	return avatarURLPrefix + filename, nil
+}
+
+// avatarURLAllowed true jika u https (port bawaan) ke host foto profil milik provider
+func avatarURLAllowed(u *url.URL, provider string) bool {
+	if u.Scheme != "https" || u.Port() != "" {
+		return false
+	}
+
+	hosts := config.GetList(strings.ToUpper(provider) + "_AVATAR_HOSTS")
+	if len(hosts) == 0 {
+		hosts = avatarHosts[provider]
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || (strings.HasPrefix(h, ".") && strings.HasSuffix(host, h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAvatar menghapus file avatar yang sudah diunduh jika profilnya batal dibuat
+func removeAvatar(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(avatarDir, strings.TrimPrefix(path, avatarURLPrefix))); err != nil {
+		log.Printf("removeAvatar %s: %v", path, err)
+	}
+}
+
+// oauthProfileName nama depan & belakang dari provider; jika provider tidak memberi nama,
+// bagian depan email dipakai supaya kolom nama (NOT NULL) tetap terisi
+func oauthProfileName(info *oauth.UserInfo) (string, *string) {
+	nama, namaBelakang := strings.TrimSpace(info.GivenName), strings.TrimSpace(info.FamilyName)
+	if nama == "" {
+		nama, namaBelakang, _ = strings.Cut(strings.TrimSpace(info.Name), " ")
+	}
+	if nama == "" {
+		nama, _, _ = strings.Cut(info.Email, "@")
+	}
+	if namaBelakang == "" {
+		return nama, nil
+	}
+	return nama, &namaBelakang
+}
+
+// createOAuthProfile membuat profiles + user_profiles untuk user baru dari data provider.
+// tanggal_lahir dibiarkan NULL sampai user melengkapinya.
+func createOAuthProfile(ctx context.Context, tx *sql.Tx, userID int, info *oauth.UserInfo, avatarPath string) error {
+	nama, namaBelakang := oauthProfileName(info)
+
+	var avatar *string
+	if avatarPath != "" {
+		avatar = &avatarPath
+	}
+
+	var profileID int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO profiles (nama, nama_belakang, avatar, is_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING id
+	`, nama, namaBelakang, avatar, info.EmailVerified).Scan(&profileID)
+	if err != nil {
+		return fmt.Errorf("insert profile: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_profiles (user_id, profile_id, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+	`, userID, profileID); err != nil {
+		return fmt.Errorf("insert user_profile: %w", err)
+	}
+	return nil
+}
+
+// userMissingProfileFields field profil wajib yang belum diisi user; user tanpa profil
+// dianggap belum mengisi semuanya
+func userMissingProfileFields(ctx context.Context, db *sql.DB, userID int) ([]string, error) {
+	var (
+		nama         string
+		tanggalLahir sql.NullTime
+	)
+	err := db.QueryRowContext(ctx, `
+		SELECT p.nama, p.tanggal_lahir
+		FROM profiles p
+		JOIN user_profiles up ON up.profile_id = p.id
+		WHERE up.user_id = $1
+		ORDER BY p.id
+		LIMIT 1
+	`, userID).Scan(&nama, &tanggalLahir)
+	if err == sql.ErrNoRows {
+		return missingProfileFields("", false), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return missingProfileFields(nama, tanggalLahir.Valid), nil
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// Setelah callback OAuth browser di-redirect kembali ke frontend:
+//   - sukses → return_to dari /oauth/:provider/login, default FRONTEND_URL + OAUTH_SUCCESS_PATH ("/");
+//     ?complete_profile=tanggal_lahir ditambahkan jika profil wajib belum lengkap
+//   - 2FA aktif → FRONTEND_URL + OAUTH_MFA_PATH ("/login/2fa"), mfa_token di fragment URL
+//   - gagal → FRONTEND_URL + OAUTH_ERROR_PATH ("/login") dengan ?error=<CODE>&provider=<name>
+//
+// return_to boleh path relatif terhadap FRONTEND_URL atau URL absolut yang diawali salah satu
+// entry OAUTH_ALLOWED_RETURN_URLS atau FRONTEND_URL. Client native memakai ?mode=json
+// saat login sehingga callback mengembalikan JSON berisi token, bukan redirect + cookie.
+
+var errInvalidReturnURL = errors.New("return URL is not allowed")
+
+// oauthFailure kegagalan alur OAuth: code dipakai di ?error= saat redirect,
+// status + message untuk response JSON
+type oauthFailure struct {
+	status  int
+	code    string
+	message string
+}
+
+var (
+	oauthErrUnknownProvider  = oauthFailure{fiber.StatusNotFound, "UNKNOWN_PROVIDER", "unknown OAuth provider"}
+	oauthErrUnavailable      = oauthFailure{fiber.StatusBadGateway, "PROVIDER_UNAVAILABLE", "OAuth provider is not available"}
+	oauthErrInvalidReturnURL = oauthFailure{fiber.StatusBadRequest, "INVALID_RETURN_URL", "return_to is not an allowed URL"}
+	oauthErrAccessDenied     = oauthFailure{fiber.StatusBadRequest, "ACCESS_DENIED", "OAuth login was cancelled or denied"}
+	oauthErrMissingCode      = oauthFailure{fiber.StatusBadRequest, "INVALID_REQUEST", "code is required"}
+	oauthErrInvalidState     = oauthFailure{fiber.StatusBadRequest, "INVALID_STATE", "invalid or expired OAuth state, please try again"}
+	oauthErrExchange         = oauthFailure{fiber.StatusBadGateway, "PROVIDER_ERROR", "failed to exchange token"}
+	oauthErrNoEmail          = oauthFailure{fiber.StatusBadRequest, "NO_EMAIL", "OAuth account has no email address"}
+	oauthErrVerify           = oauthFailure{fiber.StatusUnauthorized, "VERIFICATION_FAILED", "failed to verify OAuth login"}
+	oauthErrLinkRequired     = oauthFailure{fiber.StatusConflict, "OAUTH_LINK_REQUIRED",
+		"An account with this email already exists. Sign in and link this provider from your account settings"}
+	oauthErrLinkedElsewhere = oauthFailure{fiber.StatusConflict, "IDENTITY_LINKED_ELSEWHERE",
+		"This provider account is already linked to another user"}
+	oauthErrProviderLinked = oauthFailure{fiber.StatusConflict, "PROVIDER_ALREADY_LINKED",
+		"Another account from this provider is already linked, unlink it first"}
+	oauthErrEmailNotVerified = oauthFailure{fiber.StatusForbidden, "EMAIL_NOT_VERIFIED", "Email not verified"}
+	oauthErrServer           = oauthFailure{fiber.StatusInternalServerError, "SERVER_ERROR", "failed to complete OAuth login"}
+)
+
+// oauthWantsJSON mode JSON dipilih saat login (?mode=json, tersimpan di state). Jika state
+// belum terbaca, client yang tidak menerima text/html dianggap client native.
+func oauthWantsJSON(c *fiber.Ctx, st *oauthState) bool {
+	if st != nil {
+		return st.JSON
+	}
+	return c.Accepts(fiber.MIMETextHTML, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON
+}
+
+// oauthError response JSON atau redirect ke halaman error frontend
+func oauthError(c *fiber.Ctx, st *oauthState, provider string, f oauthFailure) error {
+	if oauthWantsJSON(c, st) {
+		data := map[string]string{"code": f.code}
+		if provider != "" {
+			data["provider"] = provider
+		}
+		return utils.Error(c, f.status, f.message, data)
+	}
+
+	q := url.Values{"error": {f.code}}
+	if provider != "" {
+		q.Set("provider", provider)
+	}
+	return c.Redirect(withQuery(frontendURL(oauthPath("OAUTH_ERROR_PATH", "/login")), q), fiber.StatusFound)
+}
+
+// frontendURL FRONTEND_URL + path
+func frontendURL(path string) string {
+	return strings.TrimSuffix(config.Get("FRONTEND_URL"), "/") + path
+}
+
+// oauthPath path halaman frontend dari env, atau def jika kosong
+func oauthPath(key, def string) string {
+	if v := config.Get(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// resolveReturnURL memvalidasi return_to terhadap allow-list dan mengembalikan URL tujuan
+// setelah login berhasil
+func resolveReturnURL(raw string) (string, error) {
+	if raw == "" {
+		return frontendURL(oauthPath("OAUTH_SUCCESS_PATH", "/")), nil
+	}
+	// tolak "//host", "/\host" dan karakter kontrol yang bisa diartikan browser sebagai host lain
+	if strings.HasPrefix(raw, "//") || strings.ContainsAny(raw, "\\\r\n\t") {
+		return "", errInvalidReturnURL
+	}
+
+	// path relatif selalu berada di FRONTEND_URL
+	if strings.HasPrefix(raw, "/") {
+		return frontendURL(raw), nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return "", errInvalidReturnURL
+	}
+
+	allowed := append(config.GetList("OAUTH_ALLOWED_RETURN_URLS"), config.Get("FRONTEND_URL"))
+	for _, a := range allowed {
+		if returnURLAllowed(u, a) {
+			return u.String(), nil
+		}
+	}
+	return "", errInvalidReturnURL
+}
+
+// returnURLAllowed u harus sama scheme dan host-nya dengan entry allow-list,
+// dan path-nya berada di bawah path entry tersebut
+func returnURLAllowed(u *url.URL, entry string) bool {
+	a, err := url.Parse(entry)
+	if err != nil || a.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, a.Scheme) || !strings.EqualFold(u.Host, a.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(a.Path, "/")
+	return prefix == "" || u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// withQuery menambahkan query parameter ke URL yang mungkin sudah punya query
+func withQuery(rawURL string, q url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	merged := u.Query()
+	for k, v := range q {
+		merged[k] = v
+	}
+	u.RawQuery = merged.Encode()
+	return u.String()
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/qwerius/gonuxt/internal/oauth"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateType   = "oauth-state+jwt"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+var errOAuthStateInvalid = errors.New("invalid or expired OAuth state")
+
+// oauthState isi cookie oauth_state (JWT bertanda tangan) selama alur login di provider
+type oauthState struct {
+	Provider   string `json:"provider"`
+	State      string `json:"state"`
+	Verifier   string `json:"verifier"` // PKCE code_verifier
+	Nonce      string `json:"nonce"`
+	LinkUserID int    `json:"link_user_id,omitempty"` // diisi jika alurnya menghubungkan akun dari /me/identities
+	ReturnTo   string `json:"return_to,omitempty"`    // tujuan redirect setelah callback, sudah divalidasi
+	JSON       bool   `json:"json,omitempty"`         // client native: callback mengembalikan JSON
+	jwt.RegisteredClaims
+}
+
+// beginOAuth membuat state + PKCE + nonce, menyimpannya di cookie, lalu mengembalikan URL login provider.
+// opts berisi LinkUserID, ReturnTo dan JSON dari pemanggil; field lainnya diisi di sini.
+func beginOAuth(ctx context.Context, c *fiber.Ctx, p *oauth.Provider, opts oauthState) (string, error) {
+	state, err := utils.RandomToken(24)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.RandomToken(24)
+	if err != nil {
+		return "", err
+	}
+	pkce, err := oauth.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	opts.Provider = p.Name
+	opts.State = state
+	opts.Verifier = pkce.Verifier
+	opts.Nonce = nonce
+	opts.RegisteredClaims = jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(oauthStateTTL)),
+	}
+	signed, err := utils.CreateSignedToken(opts, oauthStateType)
+	if err != nil {
+		return "", err
+	}
+
+	extra := pkce.AuthParams()
+	extra.Set("state", state)
+	if p.Type == oauth.TypeOIDC {
+		extra.Set("nonce", nonce)
+	}
+	authURL, err := p.AuthCodeURL(ctx, oauthRedirectURI(c, p), extra)
+	if err != nil {
+		return "", err
+	}
+
+	// Lax: cookie tetap terkirim saat provider me-redirect balik (GET top-level)
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    signed,
+		Path:     "/api/v1/oauth",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HTTPOnly: true,
+		SameSite: "Lax",
+		Secure:   c.Protocol() == "https",
+	})
+	return authURL, nil
+}
+
+// finishOAuthState memvalidasi cookie oauth_state terhadap provider dan parameter state
+// di callback. Cookie langsung dihapus supaya state hanya bisa dipakai sekali.
+func finishOAuthState(c *fiber.Ctx, p *oauth.Provider) (*oauthState, error) {
+	raw := c.Cookies(oauthStateCookie)
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/api/v1/oauth",
+		MaxAge:   -1,
+		HTTPOnly: true,
+		SameSite: "Lax",
+		Secure:   c.Protocol() == "https",
+	})
+	if raw == "" {
+		return nil, errOAuthStateInvalid
+	}
+
+	var st oauthState
+	if err := utils.ParseSignedToken(raw, oauthStateType, &st); err != nil {
+		return nil, errOAuthStateInvalid
+	}
+	if st.Provider != p.Name || st.State == "" ||
+		subtle.ConstantTimeCompare([]byte(st.State), []byte(c.Query("state"))) != 1 {
+		return nil, errOAuthStateInvalid
+	}
+	return &st, nil
+}
+
+// exchangeParams parameter PKCE untuk token endpoint
+func (st *oauthState) exchangeParams() url.Values {
+	return oauth.PKCE{Verifier: st.Verifier}.ExchangeParams()
+}
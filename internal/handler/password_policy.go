@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/password"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// checkPasswordPolicy memvalidasi password baru; personal berisi email / nama user.
+// Mengembalikan nil jika lolos policy.
+func checkPasswordPolicy(field, plain string, personal ...string) []utils.FieldError {
+	violations := password.Validate(plain, personal...)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	errs := make([]utils.FieldError, 0, len(violations))
+	for _, v := range violations {
+		errs = append(errs, utils.FieldError{Field: field, Code: v.Code, Message: v.Message})
+	}
+	return errs
+}
+
+// passwordPolicyError response 422 untuk password yang melanggar policy
+func passwordPolicyError(c *fiber.Ctx, errs []utils.FieldError) error {
+	return utils.ValidationError(c, "Password does not meet the password policy", errs)
+}
+
+// userPersonalInfo email dan nama profile user, dipakai untuk menolak password
+// yang memuat data pribadi
+func userPersonalInfo(ctx context.Context, db *sql.DB, userID int) ([]string, error) {
+	var email string
+	if err := db.QueryRowContext(ctx, `SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+		return nil, err
+	}
+	info := []string{email}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.nama, COALESCE(p.nama_belakang, '')
+		FROM profiles p
+		JOIN user_profiles up ON up.profile_id = p.id
+		WHERE up.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var first, last string
+		if err := rows.Scan(&first, &last); err != nil {
+			return nil, err
+		}
+		info = append(info, first, last)
+	}
+	return info, rows.Err()
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+type PermissionHandler struct {
+	DB          *sql.DB
+	Permissions *store.PermissionStore
+}
+
+func NewPermissionHandler(db *sql.DB) *PermissionHandler {
+	return &PermissionHandler{DB: db, Permissions: store.NewPermissionStore(db)}
+}
+
+type PermissionResponse struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type GrantPermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// GetAllPermissions GET /permissions
+func (h *PermissionHandler) GetAllPermissions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	perms, err := h.Permissions.List(ctx)
+	if err != nil {
+		log.Printf("GetAllPermissions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get permissions")
+	}
+
+	return utils.SuccessMessage(c, "permissions retrieved successfully", permissionResponses(perms), nil)
+}
+
+// GetRolePermissions GET /roles/:id/permissions
+func (h *PermissionHandler) GetRolePermissions(c *fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	perms, err := h.Permissions.ForRole(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, store.ErrRoleNotFound) {
+			return utils.Error(c, fiber.StatusNotFound, "role not found")
+		}
+		log.Printf("GetRolePermissions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to get role permissions")
+	}
+
+	return utils.SuccessMessage(c, "role permissions retrieved successfully", permissionResponses(perms), nil)
+}
+
+// GrantRolePermissions POST /roles/:id/permissions { "permissions": ["users:write"] }
+func (h *PermissionHandler) GrantRolePermissions(c *fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role id")
+	}
+
+	var req GrantPermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	if len(req.Permissions) == 0 {
+		return utils.Error(c, fiber.StatusBadRequest, "permissions is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.Permissions.Grant(ctx, roleID, req.Permissions); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRoleNotFound):
+			return utils.Error(c, fiber.StatusNotFound, "role not found")
+		case errors.Is(err, store.ErrPermissionUnknown):
+			return utils.Error(c, fiber.StatusBadRequest, "unknown permission", map[string]interface{}{
+				"allowed_permissions": store.PermissionNames,
+			})
+		}
+		log.Printf("GrantRolePermissions: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to grant permissions")
+	}
+
+	store.Principals.InvalidateAll()
+
+	return h.GetRolePermissions(c)
+}
+
+// RevokeRolePermission DELETE /roles/:id/permissions/:permission
+func (h *PermissionHandler) RevokeRolePermission(c *fiber.Ctx) error {
+	roleID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role id")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var roleName string
+	err = h.DB.QueryRowContext(ctx, "SELECT name FROM roles WHERE id = $1", roleID).Scan(&roleName)
+	if err == sql.ErrNoRows {
+		return utils.Error(c, fiber.StatusNotFound, "role not found")
+	}
+	if err != nil {
+		log.Printf("RevokeRolePermission: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke permission")
+	}
+	// admin tidak boleh kehilangan akses, termasuk akses untuk mengembalikan permission
+	if roleName == store.AdminRole {
+		return utils.Error(c, fiber.StatusForbidden, "the built-in admin role always has every permission")
+	}
+
+	revoked, err := h.Permissions.Revoke(ctx, roleID, c.Params("permission"))
+	if err != nil {
+		log.Printf("RevokeRolePermission: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to revoke permission")
+	}
+	if !revoked {
+		return utils.Error(c, fiber.StatusNotFound, "role does not have this permission")
+	}
+
+	store.Principals.InvalidateAll()
+
+	return utils.SuccessMessage(c, "permission revoked successfully", nil, nil)
+}
+
+func permissionResponses(perms []store.Permission) []PermissionResponse {
+	resp := make([]PermissionResponse, 0, len(perms))
+	for _, p := range perms {
+		resp = append(resp, PermissionResponse{ID: p.ID, Name: p.Name, Description: p.Description})
+	}
+	return resp
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/password"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// ReauthenticateRequest salah satu: password, kode TOTP, atau recovery code
+type ReauthenticateRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// Reauthenticate POST /auth/reauthenticate → user yang sudah login membuktikan ulang
+// identitasnya (step-up) supaya bisa memakai route dengan RequireRecentAuth.
+// auth_time sesi diperbarui dan access token baru diterbitkan.
+func (h *AuthHandler) Reauthenticate(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+	if sessionID == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "token is not bound to a login session, please sign in again")
+	}
+
+	var req ReauthenticateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.Password == "" && req.Code == "" && req.RecoveryCode == "" {
+		return utils.Error(c, fiber.StatusBadRequest, "password, code or recovery_code is required")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var (
+		email          string
+		hashedPassword sql.NullString
+		lockedUntil    sql.NullTime
+	)
+	err := h.DB.QueryRowContext(ctx,
+		"SELECT email, password, locked_until FROM users WHERE id = $1", userID,
+	).Scan(&email, &hashedPassword, &lockedUntil)
+	if err != nil {
+		log.Printf("Reauthenticate: failed to query user: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	// lockout berlaku sama seperti Login supaya endpoint ini tidak jadi jalan brute force
+	locked := lockedUntil.Valid && time.Now().Before(lockedUntil.Time)
+
+	if req.Password != "" {
+		if locked {
+			password.SimulateVerify(req.Password)
+			return utils.Error(c, fiber.StatusUnauthorized, "Invalid password")
+		}
+		if !hashedPassword.Valid {
+			password.SimulateVerify(req.Password)
+			return utils.Error(c, fiber.StatusBadRequest, "account has no password, use your 2FA code or sign in again")
+		}
+		passwordOK, _, err := password.Verify(req.Password, hashedPassword.String)
+		if err != nil {
+			log.Printf("Reauthenticate: failed to verify password for user=%d: %v", userID, err)
+		}
+		if !passwordOK {
+			h.recordLoginFailure(ctx, userID, email)
+			return utils.Error(c, fiber.StatusUnauthorized, "Invalid password")
+		}
+		if _, err := h.Lockout.Reset(ctx, userID); err != nil {
+			log.Printf("Reauthenticate: failed to reset failed login count: %v", err)
+		}
+	} else if locked {
+		return mfaVerifyError(c, "Reauthenticate", store.ErrMFAInvalidCode)
+	} else if err := h.MFA.Verify(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, store.ErrMFAInvalidCode) {
+			h.recordLoginFailure(ctx, userID, email)
+		}
+		return mfaVerifyError(c, "Reauthenticate", err)
+	}
+
+	authTime := time.Now()
+	if err := h.RefreshTokens.Reauthenticated(ctx, sessionID, authTime); err != nil {
+		if errors.Is(err, store.ErrRefreshTokenInvalid) {
+			return utils.Error(c, fiber.StatusUnauthorized, "session has ended, please sign in again")
+		}
+		log.Printf("Reauthenticate: failed to update session: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to update session")
+	}
+
+	accessToken, err := utils.CreateAccessToken(userID, sessionID, authTime)
+	if err != nil {
+		log.Printf("Reauthenticate: failed to create access token: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "Failed to create access token")
+	}
+
+	data := map[string]interface{}{
+		"auth_time": authTime.UTC().Format(time.RFC3339),
+	}
+	// klien cookie mendapat cookie baru, klien Bearer mendapat token di body
+	if c.Get("Authorization") == "" {
+		setAccessCookie(c, accessToken, int(utils.AccessTokenTTL.Seconds()))
+	} else {
+		data["access_token"] = accessToken
+		data["token_type"] = "Bearer"
+	}
+
+	return utils.SuccessMessage(c, "Re-authentication successful", data, nil)
+}
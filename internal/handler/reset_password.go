@@ -2,12 +2,12 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
 	"log"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/qwerius/gonuxt/internal/utils"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/qwerius/gonuxt/internal/password"
+	"github.com/qwerius/gonuxt/internal/store"
 )
 
 func ResetPassword(db *sql.DB) fiber.Handler {
@@ -22,29 +22,55 @@ func ResetPassword(db *sql.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 		}
 
-		// 1️⃣ validasi token JWT
-		userID, err := utils.ValidateAccessToken(req.Token)
+		if req.Token == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "token is required"})
+		}
+
+		resets := store.NewPasswordResetStore(db)
+
+		// 1️⃣ validasi password baru (butuh pemilik token untuk cek email / nama)
+		userID, err := resets.Lookup(c.Context(), req.Token)
 		if err != nil {
-			log.Println("JWT validation error:", err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+			if errors.Is(err, store.ErrResetTokenInvalid) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+			}
+			log.Println("DB lookup error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update password"})
+		}
+
+		personal, err := userPersonalInfo(c.Context(), db, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println("DB lookup error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update password"})
+		}
+		if errs := checkPasswordPolicy("new_password", req.NewPassword, personal...); errs != nil {
+			return passwordPolicyError(c, errs)
 		}
 
 		// 2️⃣ hash password baru
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		hashedPassword, err := password.Hash(req.NewPassword)
 		if err != nil {
 			log.Println("Password hash error:", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to hash password"})
 		}
 
-		// 3️⃣ update password di DB
-		query := "UPDATE users SET password = $1, updated_at = $2 WHERE id = $3"
-		_, err = db.Exec(query, hashedPassword, time.Now(), userID)
+		// 3️⃣ pakai token reset (sekali pakai) + update password di DB
+		userID, err = resets.Reset(c.Context(), req.Token, hashedPassword)
 		if err != nil {
+			if errors.Is(err, store.ErrResetTokenInvalid) {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+			}
 			log.Println("DB update error:", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to update password"})
 		}
 
-		// 4️⃣ respon sukses
+		// 4️⃣ token & sesi lama tidak berlaku lagi setelah password diganti
+		if err := logoutEverywhere(c.Context(), db, userID); err != nil {
+			log.Println("Revoke sessions error:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to revoke sessions"})
+		}
+
+		// 5️⃣ respon sukses
 		return c.JSON(fiber.Map{"message": "Password berhasil diubah"})
 	}
 }
@@ -3,30 +3,54 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/middleware"
+	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
 )
 
 type RoleHandler struct {
-	DB *sql.DB
+	DB          *sql.DB
+	Roles       *store.RoleStore
+	Permissions *store.PermissionStore
 }
 
 func NewRoleHandler(db *sql.DB) *RoleHandler {
-	return &RoleHandler{DB: db}
+	return &RoleHandler{
+		DB:          db,
+		Roles:       store.NewRoleStore(db),
+		Permissions: store.NewPermissionStore(db),
+	}
 }
 
 type RoleResponse struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id,omitempty"` // role yang diwarisi
 }
 
 type CreateRoleRequest struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// SetRoleParentRequest parent_id null untuk berhenti mewarisi
+type SetRoleParentRequest struct {
+	ParentID *int `json:"parent_id"`
+}
+
+// EffectiveRoleResponse role milik user, termasuk yang diwarisi lewat parent role
+type EffectiveRoleResponse struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Inherited bool     `json:"inherited"`
+	Path      []string `json:"path,omitempty"` // mis. ["moderator", "pelanggan"] jika diwarisi
 }
 
 // GetAllRoles GET /roles (dengan pagination)
@@ -45,7 +69,7 @@ func (h *RoleHandler) GetAllRoles(c *fiber.Ctx) error {
 
 	// query role dengan limit offset
 	rows, err := h.DB.QueryContext(ctx,
-		`SELECT id, name
+		`SELECT id, name, parent_id
 		 FROM roles
 		 ORDER BY id
 		 LIMIT $1 OFFSET $2`,
@@ -60,7 +84,7 @@ func (h *RoleHandler) GetAllRoles(c *fiber.Ctx) error {
 	roles := []RoleResponse{}
 	for rows.Next() {
 		var r RoleResponse
-		if err := rows.Scan(&r.ID, &r.Name); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.ParentID); err != nil {
 			log.Printf("GetAllRoles: failed to scan role: %v", err)
 			return utils.Error(c, fiber.StatusInternalServerError, "failed to scan roles")
 		}
@@ -103,10 +127,10 @@ func (h *RoleHandler) GetRoleByID(c *fiber.Ctx) error {
 
 	var r RoleResponse
 	err = h.DB.QueryRowContext(ctx, `
-		SELECT id, name
+		SELECT id, name, parent_id
 		FROM roles
 		WHERE id = $1
-	`, id).Scan(&r.ID, &r.Name)
+	`, id).Scan(&r.ID, &r.Name, &r.ParentID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -148,6 +172,9 @@ func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
 		return utils.Error(c, fiber.StatusNotFound, "role not found")
 	}
 
+	// user dengan role ini (atau turunannya) kehilangan permission-nya
+	store.Principals.InvalidateAll()
+
 	return utils.SuccessMessage(c, "role deleted successfully", nil, nil)
 }
 
@@ -166,69 +193,150 @@ func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
+	// role baru belum punya turunan, jadi parent mana pun tidak bisa membentuk siklus
 	var id int
 	err := h.DB.QueryRowContext(ctx, `
-		INSERT INTO roles (name)
-		VALUES ($1)
+		INSERT INTO roles (name, parent_id)
+		SELECT $1, $2
+		WHERE $2::INT IS NULL OR EXISTS(SELECT 1 FROM roles WHERE id = $2)
 		RETURNING id
-	`, req.Name).Scan(&id)
+	`, req.Name, req.ParentID).Scan(&id)
 
+	if err == sql.ErrNoRows {
+		return utils.Error(c, fiber.StatusBadRequest, "parent role not found")
+	}
 	if err != nil {
 		log.Printf("CreateRole: failed to insert role: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to create role")
 	}
 
 	role := RoleResponse{
-		ID:   id,
-		Name: req.Name,
+		ID:       id,
+		Name:     req.Name,
+		ParentID: req.ParentID,
 	}
 
 	return utils.SuccessMessage(c, "role created successfully", role, nil)
 }
 
-// GetMyRole GET /role
-func (h *RoleHandler) GetMyRole(c *fiber.Ctx) error {
-	// ambil user_id dari Locals
-	userIDAny := c.Locals("user_id")
-	if userIDAny == nil {
-		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+// SetRoleParent PUT /roles/:id/parent { "parent_id": 2 } → role :id mewarisi role 2
+func (h *RoleHandler) SetRoleParent(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid role id")
 	}
 
-	userID, ok := userIDAny.(int)
-	if !ok {
-		return utils.Error(c, fiber.StatusUnauthorized, "invalid user context")
+	var req SetRoleParentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid request body")
 	}
 
 	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
 	defer cancel()
 
-	rows, err := h.DB.QueryContext(ctx,
-		`SELECT r.id, r.name
-		 FROM roles r
-		 JOIN user_roles ur ON ur.role_id = r.id
-		 WHERE ur.user_id = $1
-		 ORDER BY r.id`,
-		userID,
-	)
+	if err := h.Roles.SetParent(ctx, id, req.ParentID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRoleNotFound):
+			return utils.Error(c, fiber.StatusNotFound, "role not found")
+		case errors.Is(err, store.ErrRoleParentNotFound):
+			return utils.Error(c, fiber.StatusBadRequest, "parent role not found")
+		case errors.Is(err, store.ErrRoleCycle):
+			return utils.Error(c, fiber.StatusConflict, "role cannot inherit from itself or one of its descendants")
+		}
+		log.Printf("SetRoleParent: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to update role")
+	}
+
+	store.Principals.InvalidateAll()
+
+	return h.GetRoleByID(c)
+}
+
+// GetMyRole GET /role → role milik user, termasuk yang diwarisi
+func (h *RoleHandler) GetMyRole(c *fiber.Ctx) error {
+	if _, ok := c.Locals("user_id").(int); !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	principal, err := middleware.GetPrincipal(c)
 	if err != nil {
-		log.Printf("GetMyRole: failed to query roles: %v", err)
+		log.Printf("GetMyRole: failed to load roles: %v", err)
 		return utils.Error(c, fiber.StatusInternalServerError, "failed to get roles")
 	}
-	defer rows.Close()
 
-	roles := []RoleResponse{}
-	for rows.Next() {
-		var r RoleResponse
-		if err := rows.Scan(&r.ID, &r.Name); err != nil {
-			log.Printf("GetMyRole: scan error: %v", err)
-			return utils.Error(c, fiber.StatusInternalServerError, "failed to read roles")
-		}
-		roles = append(roles, r)
+	effective := principal.Roles
+	if len(effective) == 0 {
+		return utils.Error(c, fiber.StatusNotFound, "user has no role")
 	}
 
-	if len(roles) == 0 {
-		return utils.Error(c, fiber.StatusNotFound, "user has no role")
+	roles := make([]EffectiveRoleResponse, 0, len(effective))
+	for _, r := range effective {
+		resp := EffectiveRoleResponse{ID: r.ID, Name: r.Name, Inherited: r.Inherited()}
+		if r.Inherited() {
+			resp.Path = r.Path
+		}
+		roles = append(roles, resp)
 	}
 
 	return utils.SuccessMessage(c, "my roles retrieved successfully", roles, nil, nil)
 }
+
+// ExplainUserPermission GET /users/:id/permissions/:permission → apakah user punya permission
+// dan lewat role mana saja (termasuk rantai pewarisan)
+func (h *RoleHandler) ExplainUserPermission(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return utils.Error(c, fiber.StatusBadRequest, "invalid user id")
+	}
+	return h.explainPermission(c, userID)
+}
+
+// ExplainMyPermission GET /me/permissions/:permission
+func (h *RoleHandler) ExplainMyPermission(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return utils.Error(c, fiber.StatusUnauthorized, "unauthorized")
+	}
+	return h.explainPermission(c, userID)
+}
+
+func (h *RoleHandler) explainPermission(c *fiber.Ctx, userID int) error {
+	permission := c.Params("permission")
+	if !validPermission(permission) {
+		return utils.Error(c, fiber.StatusNotFound, "unknown permission")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	grants, err := h.Permissions.Explain(ctx, userID, permission)
+	if err != nil {
+		log.Printf("ExplainPermission: %v", err)
+		return utils.Error(c, fiber.StatusInternalServerError, "failed to check permission")
+	}
+
+	type grantResponse struct {
+		Role string   `json:"role"`
+		Path []string `json:"path"`
+	}
+	via := make([]grantResponse, 0, len(grants))
+	for _, g := range grants {
+		via = append(via, grantResponse{Role: g.Role, Path: g.Path})
+	}
+
+	return utils.SuccessMessage(c, "permission checked successfully", map[string]interface{}{
+		"user_id":    userID,
+		"permission": permission,
+		"granted":    len(grants) > 0,
+		"grants":     via,
+	}, nil)
+}
+
+func validPermission(name string) bool {
+	for _, p := range store.PermissionNames {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Package handler verifikasi email setelah register
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+const (
+	verifyEmailPurpose = "verify_email"
+	verifyEmailTTL     = 24 * time.Hour
+)
+
+// VerifyEmail POST /auth/verify-email { "token": "..." }
+func VerifyEmail(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		type Request struct {
+			Token string `json:"token"`
+		}
+		var req Request
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return utils.Error(c, fiber.StatusBadRequest, "token is required")
+		}
+
+		userID, claims, err := utils.ParseActionToken(req.Token, verifyEmailPurpose)
+		if err != nil {
+			return utils.Error(c, fiber.StatusBadRequest, "invalid or expired verification token")
+		}
+
+		// token hanya boleh dipakai sekali
+		used, err := store.Revocations.IsRevoked(c.Context(), claims.ID)
+		if err != nil {
+			log.Printf("VerifyEmail: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to verify email")
+		}
+		if used {
+			return utils.Error(c, fiber.StatusBadRequest, "invalid or expired verification token")
+		}
+
+		tx, err := db.BeginTx(c.Context(), nil)
+		if err != nil {
+			log.Printf("VerifyEmail: failed to begin tx: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to verify email")
+		}
+		defer tx.Rollback()
+
+		res, err := tx.ExecContext(c.Context(), `
+			UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND email_verified_at IS NULL
+		`, userID)
+		if err != nil {
+			log.Printf("VerifyEmail: failed to update user: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to verify email")
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return utils.Error(c, fiber.StatusBadRequest, "email already verified or user not found")
+		}
+
+		// profile (jika sudah ada) ikut ditandai terverifikasi
+		if _, err := tx.ExecContext(c.Context(), `
+			UPDATE profiles SET is_verified = TRUE, updated_at = NOW()
+			WHERE id IN (SELECT profile_id FROM user_profiles WHERE user_id = $1)
+		`, userID); err != nil {
+			log.Printf("VerifyEmail: failed to update profile: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to verify email")
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("VerifyEmail: commit failed: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "failed to verify email")
+		}
+
+		if err := store.Revocations.Revoke(c.Context(), claims.ID, userID, claims.ExpiresAt.Time); err != nil {
+			log.Printf("VerifyEmail: failed to consume token: %v", err)
+		}
+
+		return utils.SuccessMessage(c, "Email verified successfully", nil, nil)
+	}
+}
+
+// ResendVerificationEmail POST /auth/resend-verification { "email": "..." }
+// Response selalu sama supaya tidak membocorkan email mana yang terdaftar.
+func ResendVerificationEmail(db *sql.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		type Request struct {
+			Email string `json:"email"`
+		}
+		var req Request
+		if err := c.BodyParser(&req); err != nil || req.Email == "" {
+			return utils.Error(c, fiber.StatusBadRequest, "email is required")
+		}
+
+		const msg = "If the account exists and is not verified yet, a verification email has been sent"
+
+		var userID int
+		var verifiedAt sql.NullTime
+		err := db.QueryRowContext(c.Context(),
+			"SELECT id, email_verified_at FROM users WHERE email = $1", req.Email,
+		).Scan(&userID, &verifiedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return utils.SuccessMessage(c, msg, nil, nil)
+			}
+			log.Printf("ResendVerificationEmail: %v", err)
+			return utils.Error(c, fiber.StatusInternalServerError, "Database error")
+		}
+
+		if !verifiedAt.Valid {
+			if err := sendVerificationEmail(userID, req.Email); err != nil {
+				log.Printf("ResendVerificationEmail: failed to send email: %v", err)
+				return utils.Error(c, fiber.StatusInternalServerError, "failed to send email")
+			}
+		}
+
+		return utils.SuccessMessage(c, msg, nil, nil)
+	}
+}
+
+// sendVerificationEmail membuat token verifikasi dan mengirim link-nya lewat SMTP
+func sendVerificationEmail(userID int, email string) error {
+	token, err := utils.CreateActionToken(userID, verifyEmailPurpose, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s", config.Get("FRONTEND_URL"), token)
+
+	body := fmt.Sprintf(`
+<p>Hai,</p>
+<p>Terima kasih sudah mendaftar. Klik link berikut untuk memverifikasi email kamu:</p>
+<p><a href="%s">%s</a></p>
+<p>Link ini berlaku 24 jam dan hanya bisa dipakai sekali.</p>
+`, verifyURL, verifyURL)
+
+	return utils.SendEmailSMTP(email, "Verifikasi Email YourApp", body)
+}
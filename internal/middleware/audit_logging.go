@@ -29,8 +29,8 @@ func AuditLoggingMiddleware(cfg *AuditConfig) fiber.Handler {
 	}
 
 	return func(c *fiber.Ctx) error {
-		// cek apakah route ini dikecualikan
-		if skipPaths[c.Path()] {
+		// cek apakah route ini dikecualikan; request dengan API key / impersonasi sudah dicatat oleh AuthRequired
+		if skipPaths[c.Path()] || c.Locals("api_key_id") != nil || c.Locals("actor_id") != nil {
 			return c.Next()
 		}
 
@@ -48,22 +48,22 @@ func AuditLoggingMiddleware(cfg *AuditConfig) fiber.Handler {
 
 		// simpan ke DB jika ada koneksi
 		if cfg.DB != nil {
-			go saveAuditToDB(cfg.DB, userID, c.Method(), c.OriginalURL(), c.Response().StatusCode(), c.IP())
+			go saveAuditToDB(cfg.DB, userID, c.Method(), c.OriginalURL(), c.Response().StatusCode(), c.IP(), nil, nil, c.Locals("org_id"))
 		}
 
 		return err
 	}
 }
 
-// simpan log ke DB
-func saveAuditToDB(db *sql.DB, userID any, method, url string, status int, ip string) {
+// simpan log ke DB; apiKeyID, actorID dan orgID (organisasi aktif dari ResolveOrg) nil jika tidak ada
+func saveAuditToDB(db *sql.DB, userID any, method, url string, status int, ip string, apiKeyID, actorID, orgID any) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	_, err := db.ExecContext(ctx,
-		`INSERT INTO audit_logs (user_id, method, url, status, ip)
-		 VALUES ($1, $2, $3, $4, $5)`,
-		userID, method, url, status, ip,
+		`INSERT INTO audit_logs (user_id, method, url, status, ip, api_key_id, actor_user_id, org_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		userID, method, url, status, ip, apiKeyID, actorID, orgID,
 	)
 	if err != nil {
 		log.Printf("saveAuditToDB error: %v", err)
@@ -1,13 +1,25 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
 	"github.com/qwerius/gonuxt/internal/utils"
 )
 
+// AuthRequired menerima access token JWT (header Bearer atau cookie access_token)
+// maupun API key (header Bearer atau X-API-Key)
 func AuthRequired(c *fiber.Ctx) error {
+	// sudah diautentikasi middleware sebelumnya (mis. group /users + route)
+	if _, ok := c.Locals("user_id").(int); ok {
+		return c.Next()
+	}
+
 	// 1. coba ambil dari header
 	authHeader := c.Get("Authorization")
 
@@ -22,6 +34,8 @@ func AuthRequired(c *fiber.Ctx) error {
 			})
 		}
 		token = parts[1]
+	} else if apiKey := c.Get(APIKeyHeaderName); apiKey != "" {
+		token = apiKey
 	} else {
 		// 2. kalau header kosong, ambil dari cookie
 		token = c.Cookies("access_token")
@@ -34,7 +48,11 @@ func AuthRequired(c *fiber.Ctx) error {
 		})
 	}
 
-	userID, err := utils.ValidateAccessToken(token)
+	if store.IsAPIKey(token) {
+		return authenticateAPIKey(c, token)
+	}
+
+	claims, err := utils.ParseAccessToken(token)
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
@@ -42,6 +60,130 @@ func AuthRequired(c *fiber.Ctx) error {
 		})
 	}
 
-	c.Locals("user_id", userID)
+	// 3. cek denylist (jti / sesi) dan watermark user
+	if revoked, err := isTokenRevoked(c, claims); err != nil {
+		log.Printf("AuthRequired: failed to check token revocation: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "failed to verify token",
+		})
+	} else if revoked {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Token has been revoked",
+		})
+	}
+
+	c.Locals("user_id", claims.UserID)
+	c.Locals("session_id", claims.SessionID)
+	c.Locals("token_claims", claims)
+	if claims.Impersonating() {
+		return impersonatedRequest(c, claims)
+	}
 	return c.Next()
 }
+
+// impersonatedRequest setiap request dengan token impersonasi dicatat di audit_logs
+// beserta admin yang sebenarnya (actor_user_id)
+func impersonatedRequest(c *fiber.Ctx, claims *utils.AccessClaims) error {
+	actorID := claims.Actor.UserID
+	c.Locals("actor_id", actorID)
+
+	err := c.Next()
+
+	ip := strings.Clone(c.IP())
+	method := strings.Clone(c.Method())
+	url := strings.Clone(c.OriginalURL())
+	status := c.Response().StatusCode()
+	log.Printf("[AUDIT] user=%d actor=%d ip=%s method=%s url=%s status=%d",
+		claims.UserID, actorID, ip, method, url, status,
+	)
+	if store.Revocations != nil {
+		go saveAuditToDB(store.Revocations.DB, claims.UserID, method, url, status, ip, nil, actorID, c.Locals("org_id"))
+	}
+
+	return err
+}
+
+func isTokenRevoked(c *fiber.Ctx, claims *utils.AccessClaims) (bool, error) {
+	if store.Revocations == nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+	defer cancel()
+
+	for _, id := range []string{claims.ID, claims.SessionID} {
+		revoked, err := store.Revocations.IsRevoked(ctx, id)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	// token impersonasi ikut dicabut saat semua token admin-nya dicabut
+	if claims.Impersonating() {
+		revoked, err := store.Revocations.IssuedBeforeWatermark(ctx, claims.Actor.UserID, issuedAt)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	return store.Revocations.IssuedBeforeWatermark(ctx, claims.UserID, issuedAt)
+}
+
+// authenticateAPIKey jalur AuthRequired untuk API key: scope dicek oleh RequireScope,
+// dan setiap pemakaian key dicatat di audit_logs
+func authenticateAPIKey(c *fiber.Ctx, token string) error {
+	if store.APIKeys == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid API key",
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+	key, err := store.APIKeys.Authenticate(ctx, token)
+	cancel()
+	if err != nil {
+		if errors.Is(err, store.ErrAPIKeyInvalid) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid API key",
+			})
+		}
+		log.Printf("AuthRequired: failed to check API key: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "failed to verify token",
+		})
+	}
+
+	c.Locals("user_id", key.UserID)
+	c.Locals("api_key", key)
+	c.Locals("api_key_id", key.ID)
+
+	ip := strings.Clone(c.IP())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := store.APIKeys.Touch(ctx, key.ID, ip); err != nil {
+			log.Printf("AuthRequired: failed to update API key usage: %v", err)
+		}
+	}()
+
+	err = c.Next()
+
+	// nilai dari fiber.Ctx di-copy karena dipakai setelah handler selesai
+	method := strings.Clone(c.Method())
+	url := strings.Clone(c.OriginalURL())
+	status := c.Response().StatusCode()
+	log.Printf("[AUDIT] user=%d api_key=%d ip=%s method=%s url=%s status=%d",
+		key.UserID, key.ID, ip, method, url, status,
+	)
+	go saveAuditToDB(store.APIKeys.DB, key.UserID, method, url, status, ip, key.ID, nil, c.Locals("org_id"))
+
+	return err
+}
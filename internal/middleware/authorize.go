@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/policy"
+)
+
+// ResourceLoader membangun policy.Resource dari request, mis. pemilik dari param :id
+type ResourceLoader func(c *fiber.Ctx) (policy.Resource, error)
+
+// Authorize dipasang setelah AuthRequired: mengevaluasi kebijakan untuk resource + action
+// dengan subject dari principal user dan field yang dikirim di body atau query string
+func Authorize(engine *policy.Engine, resourceType, action string, load ResourceLoader) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, err := GetPrincipal(c)
+		if err != nil {
+			if err == errNoUser {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "unauthorized",
+				})
+			}
+			log.Printf("Authorize: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+
+		resource, err := load(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		resource.Type = resourceType
+
+		decision := engine.Evaluate(policy.Request{
+			Subject: policy.Subject{
+				ID:          principal.UserID,
+				Roles:       principal.RoleNames(),
+				Permissions: principal.PermissionNames(),
+			},
+			Action:   action,
+			Resource: resource,
+			Fields:   requestFields(c),
+		})
+		if !decision.Allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":  "Access denied: " + decision.Reason,
+				"policy": decision.Policy,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// OwnerFromParam resource yang pemiliknya user dengan id di param tersebut (mis. /users/:id/profile)
+func OwnerFromParam(param string) ResourceLoader {
+	return func(c *fiber.Ctx) (policy.Resource, error) {
+		id, err := strconv.Atoi(c.Params(param))
+		if err != nil {
+			return policy.Resource{}, errors.New("invalid " + param + " param")
+		}
+		return policy.Resource{OwnerID: id}, nil
+	}
+}
+
+// requestFields nama field yang dikirim: query string yang tidak kosong (c.FormValue juga
+// membacanya), ditambah key JSON tingkat atas atau field form / file yang tidak kosong di body
+func requestFields(c *fiber.Ctx) []string {
+	var fields []string
+
+	c.Request().URI().QueryArgs().VisitAll(func(k, v []byte) {
+		if len(v) > 0 {
+			fields = append(fields, string(k))
+		}
+	})
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return fields
+		}
+		for k, v := range form.Value {
+			if len(v) > 0 && v[0] != "" {
+				fields = append(fields, k)
+			}
+		}
+		for k, v := range form.File {
+			if len(v) > 0 {
+				fields = append(fields, k)
+			}
+		}
+		return fields
+	}
+
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		c.Request().PostArgs().VisitAll(func(k, v []byte) {
+			if len(v) > 0 {
+				fields = append(fields, string(k))
+			}
+		})
+		return fields
+	}
+
+	var body map[string]interface{}
+	if len(c.Body()) > 0 && c.BodyParser(&body) == nil {
+		for k := range body {
+			fields = append(fields, k)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/policy"
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+func TestRequestFields(t *testing.T) {
+	multipartBody := func() (string, io.Reader) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		w.WriteField("nama", "Budi")
+		w.WriteField("nama_belakang", "")
+		w.Close()
+		return w.FormDataContentType(), &buf
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        func() (string, io.Reader)
+		want        []string
+	}{
+		{
+			name:        "json body",
+			target:      "/",
+			contentType: fiber.MIMEApplicationJSON,
+			body:        func() (string, io.Reader) { return "", strings.NewReader(`{"nama":"Budi","is_verified":true}`) },
+			want:        []string{"is_verified", "nama"},
+		},
+		{
+			name:        "urlencoded body skips empty values",
+			target:      "/",
+			contentType: fiber.MIMEApplicationForm,
+			body:        func() (string, io.Reader) { return "", strings.NewReader("nama=Budi&is_verified=") },
+			want:        []string{"nama"},
+		},
+		{
+			name:   "multipart body",
+			target: "/",
+			body:   multipartBody,
+			want:   []string{"nama"},
+		},
+		{
+			// c.FormValue membaca query string lebih dulu, jadi field di query harus ikut dicek kebijakan
+			name:   "query string with multipart body",
+			target: "/?is_verified=true",
+			body:   multipartBody,
+			want:   []string{"is_verified", "nama"},
+		},
+		{
+			name:        "query string without body",
+			target:      "/?is_verified=true&empty=",
+			contentType: fiber.MIMEApplicationJSON,
+			body:        func() (string, io.Reader) { return "", nil },
+			want:        []string{"is_verified"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			app := fiber.New()
+			app.Put("/", func(c *fiber.Ctx) error {
+				got = requestFields(c)
+				return nil
+			})
+
+			contentType, body := tt.body()
+			if contentType == "" {
+				contentType = tt.contentType
+			}
+			req := httptest.NewRequest(http.MethodPut, tt.target, body)
+			req.Header.Set(fiber.HeaderContentType, contentType)
+			if _, err := app.Test(req); err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("requestFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// is_verified di query string tidak boleh melewati kebijakan profile-verification-admin-only,
+// karena handler profil membacanya dengan c.FormValue
+func TestAuthorizeProfileVerificationFromQueryString(t *testing.T) {
+	engine, err := policy.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		roles  []string
+		target string
+		body   string
+		want   int
+	}{
+		{"owner updates own profile", nil, "/users/7/profile", "nama=Budi", fiber.StatusOK},
+		{"owner sets is_verified in body", nil, "/users/7/profile", "is_verified=true", fiber.StatusForbidden},
+		{"owner sets is_verified in query", nil, "/users/7/profile?is_verified=true", "nama=Budi", fiber.StatusForbidden},
+		{"admin sets is_verified in query", []string{"admin"}, "/users/7/profile?is_verified=true", "nama=Budi", fiber.StatusOK},
+		{"other user", nil, "/users/8/profile", "nama=Budi", fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal := &store.Principal{UserID: 7}
+			for _, r := range tt.roles {
+				principal.Roles = append(principal.Roles, store.EffectiveRole{Name: r, Path: []string{r}})
+			}
+
+			app := fiber.New()
+			app.Put("/users/:id/profile",
+				func(c *fiber.Ctx) error {
+					c.Locals("user_id", principal.UserID)
+					c.Locals(principalLocalsKey, principal)
+					return c.Next()
+				},
+				Authorize(engine, "profile", "update", OwnerFromParam("id")),
+				func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+			)
+
+			req := httptest.NewRequest(http.MethodPut, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
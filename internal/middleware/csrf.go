@@ -1,6 +1,11 @@
 package middleware
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+)
 
 const (
 	CSRFCookieName = "csrf_token"
@@ -9,10 +14,15 @@ const (
 
 // SkipCSRFPaths adalah Routes yang dilewati CSRF
 var SkipCSRFPaths = map[string]bool{
-	"/api/v1/auth/login":           true,
-	"/api/v1/auth/register":        true,
-	"/api/v1/auth/forgot-password": true,
-	"/api/v1/auth/reset-password":  true,
+	"/api/v1/auth/login":               true,
+	"/api/v1/auth/register":            true,
+	"/api/v1/auth/2fa/verify":          true,
+	"/api/v1/auth/magic-link":          true,
+	"/api/v1/auth/magic-link/consume":  true,
+	"/api/v1/auth/verify-email":        true,
+	"/api/v1/auth/resend-verification": true,
+	"/api/v1/auth/forgot-password":     true,
+	"/api/v1/auth/reset-password":      true,
 }
 
 func CSRF() fiber.Handler {
@@ -29,6 +39,11 @@ func CSRF() fiber.Handler {
 			return c.Next()
 		}
 
+		// API key dikirim lewat header (bukan cookie) sehingga tidak rentan CSRF
+		if c.Get(APIKeyHeaderName) != "" || store.IsAPIKey(strings.TrimPrefix(c.Get("Authorization"), "Bearer ")) {
+			return c.Next()
+		}
+
 		cookieToken := c.Cookies(CSRFCookieName)
 		if cookieToken == "" {
 			return fiber.ErrForbidden
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+// OrgHeader header untuk memilih organisasi aktif
+const OrgHeader = "X-Org-ID"
+
+// orgScopeLocalsKey key c.Locals untuk store.OrgScope; org_id juga diisi untuk audit_logs
+const orgScopeLocalsKey = "org_scope"
+
+// ResolveOrg dipasang setelah AuthRequired: menentukan organisasi aktif dari header X-Org-ID.
+// User harus anggota organisasi tersebut, kecuali superadmin (permission orgs:manage).
+// Tanpa header, user yang hanya tergabung di satu organisasi otomatis memakai organisasi itu;
+// anggota beberapa organisasi wajib mengirim header, sedangkan superadmin melihat semua data.
+func ResolveOrg(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	principal, err := GetPrincipal(c)
+	if err != nil {
+		log.Printf("ResolveOrg: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	scope := store.OrgScope{Superadmin: principal.HasPermission(store.PermissionOrgsManage)}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+	defer cancel()
+
+	if header := c.Get(OrgHeader); header != "" {
+		orgID, err := strconv.Atoi(header)
+		if err != nil || orgID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid " + OrgHeader + " header",
+			})
+		}
+		return useOrg(ctx, c, "ResolveOrg", scope, orgID)
+	}
+
+	if !scope.Superadmin {
+		memberships, err := store.Organizations.Memberships(ctx, userID)
+		if err != nil {
+			log.Printf("ResolveOrg: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if len(memberships) > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": OrgHeader + " header is required for members of several organizations",
+			})
+		}
+		for id, role := range memberships {
+			scope.OrgID, scope.Role = id, role
+		}
+	}
+
+	setOrgScope(c, scope)
+	return c.Next()
+}
+
+// RequireOrgRole dipasang setelah AuthRequired di route /orgs/:orgId: user harus anggota
+// organisasi dengan salah satu role tersebut (kosong = anggota mana pun). Superadmin selalu lolos.
+// Organisasi di param menjadi organisasi aktif request.
+func RequireOrgRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("user_id").(int); !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+
+		orgID, err := strconv.Atoi(c.Params("orgId"))
+		if err != nil || orgID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid organization id",
+			})
+		}
+
+		principal, err := GetPrincipal(c)
+		if err != nil {
+			log.Printf("RequireOrgRole: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		scope := store.OrgScope{Superadmin: principal.HasPermission(store.PermissionOrgsManage)}
+
+		ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+		defer cancel()
+
+		if scope.Superadmin || len(roles) == 0 {
+			return useOrg(ctx, c, "RequireOrgRole", scope, orgID)
+		}
+
+		role, err := store.Organizations.MemberRole(ctx, orgID, c.Locals("user_id").(int))
+		if err != nil && !errors.Is(err, store.ErrOrgNotMember) {
+			log.Printf("RequireOrgRole: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		for _, r := range roles {
+			if r == role {
+				scope.OrgID, scope.Role = orgID, role
+				setOrgScope(c, scope)
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":          "Access denied: insufficient organization role",
+			"required_roles": roles,
+		})
+	}
+}
+
+// GetOrgScope organisasi aktif dari ResolveOrg / RequireOrgRole. Jika keduanya tidak
+// dipasang, hasilnya scope paling sempit (tanpa organisasi, bukan superadmin).
+func GetOrgScope(c *fiber.Ctx) store.OrgScope {
+	scope, _ := c.Locals(orgScopeLocalsKey).(store.OrgScope)
+	return scope
+}
+
+// useOrg memakai orgID sebagai organisasi aktif: anggota, atau superadmin walaupun bukan anggota
+func useOrg(ctx context.Context, c *fiber.Ctx, fn string, scope store.OrgScope, orgID int) error {
+	userID := c.Locals("user_id").(int)
+
+	role, err := store.Organizations.MemberRole(ctx, orgID, userID)
+	switch {
+	case errors.Is(err, store.ErrOrgNotMember) && scope.Superadmin:
+		if _, err := store.Organizations.Get(ctx, orgID, userID); err != nil {
+			if errors.Is(err, store.ErrOrgNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "organization not found",
+				})
+			}
+			log.Printf("%s: %v", fn, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+	case errors.Is(err, store.ErrOrgNotMember):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Access denied: not a member of this organization",
+		})
+	case err != nil:
+		log.Printf("%s: %v", fn, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+
+	scope.OrgID, scope.Role = orgID, role
+	setOrgScope(c, scope)
+	return c.Next()
+}
+
+func setOrgScope(c *fiber.Ctx, scope store.OrgScope) {
+	c.Locals(orgScopeLocalsKey, scope)
+	if scope.OrgID > 0 {
+		c.Locals("org_id", scope.OrgID)
+	}
+}
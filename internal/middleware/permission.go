@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+// RequirePermission dipasang setelah AuthRequired: user harus punya role (langsung atau
+// diwarisi) yang diberi permission tersebut di role_permissions. Jika setiap jalur pemberi
+// permission melewati role di MFA_REQUIRED_ROLES, permission baru bisa dipakai setelah 2FA
+// aktif, sama seperti RoleMiddleware.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Cannot verify permission: user not found in context",
+			})
+		}
+
+		principal, err := GetPrincipal(c)
+		if err != nil {
+			log.Printf("RequirePermission: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+
+		grants := principal.Permissions[permission]
+		if len(grants) == 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":               "Access denied: insufficient permissions",
+				"required_permission": permission,
+			})
+		}
+
+		for _, g := range grants {
+			if !pathRequiresMFA(g.Path) {
+				return c.Next()
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+		defer cancel()
+
+		var enabled bool
+		err = store.Principals.DB.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)
+		`, userID).Scan(&enabled)
+		if err != nil {
+			log.Printf("RequirePermission: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Database error",
+			})
+		}
+		if !enabled {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Two-factor authentication is required for this role",
+				"code":  "MFA_ENROLLMENT_REQUIRED",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func pathRequiresMFA(path []string) bool {
+	for _, r := range path {
+		if roleRequiresMFA(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+// principalLocalsKey key c.Locals untuk *store.Principal
+const principalLocalsKey = "principal"
+
+// errNoUser GetPrincipal dipanggil sebelum AuthRequired
+var errNoUser = errors.New("user not found in context")
+
+// LoadPrincipal dipasang setelah AuthRequired: memuat role & permission user (dari cache
+// store.Principals) sekali per request dan menaruhnya di c.Locals("principal").
+// RequirePermission, RoleMiddleware dan Authorize juga memuatnya sendiri
+// jika middleware ini tidak dipasang.
+func LoadPrincipal(c *fiber.Ctx) error {
+	if _, err := GetPrincipal(c); err != nil {
+		if err == errNoUser {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "unauthorized",
+			})
+		}
+		log.Printf("LoadPrincipal: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Database error",
+		})
+	}
+	return c.Next()
+}
+
+// GetPrincipal principal user yang sedang login; dimuat sekali lalu disimpan di c.Locals
+func GetPrincipal(c *fiber.Ctx) (*store.Principal, error) {
+	if p, ok := c.Locals(principalLocalsKey).(*store.Principal); ok {
+		return p, nil
+	}
+
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return nil, errNoUser
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+	defer cancel()
+
+	p, err := store.Principals.Load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	c.Locals(principalLocalsKey, p)
+	return p, nil
+}
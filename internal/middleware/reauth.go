@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// ReauthRequiredCode kode error saat login terlalu lama; frontend meminta password / kode 2FA
+// lalu memanggil POST /auth/reauthenticate dan mengulang request
+const ReauthRequiredCode = "REAUTHENTICATION_REQUIRED"
+
+// RequireRecentAuth dipasang setelah AuthRequired untuk operasi sensitif: claim auth_time
+// harus tidak lebih lama dari maxAge. API key tidak punya auth_time, dan auth_time token
+// impersonasi milik admin, bukan user-nya, sehingga keduanya ditolak seperti RequireSession.
+func RequireRecentAuth(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Locals("api_key") != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "This endpoint cannot be used with an API key",
+			})
+		}
+
+		claims, ok := c.Locals("token_claims").(*utils.AccessClaims)
+		if c.Locals("actor_id") != nil || (ok && claims.Impersonating()) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "This endpoint cannot be used while impersonating a user",
+			})
+		}
+
+		if ok && claims.AuthenticatedWithin(maxAge) {
+			return c.Next()
+		}
+
+		// RFC 9470 (OAuth 2.0 Step Up Authentication Challenge)
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_user_authentication", max_age=`+
+			strconv.Itoa(int(maxAge.Seconds())))
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Please re-enter your password to continue",
+			"code":    ReauthRequiredCode,
+			"max_age": int(maxAge.Seconds()),
+		})
+	}
+}
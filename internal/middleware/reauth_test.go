@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/qwerius/gonuxt/internal/store"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+func TestRequireRecentAuth(t *testing.T) {
+	fresh := jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	stale := jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		locals map[string]interface{}
+		want   int
+	}{
+		{
+			name:   "recent login",
+			locals: map[string]interface{}{"token_claims": &utils.AccessClaims{UserID: 7, AuthTime: fresh}},
+			want:   fiber.StatusOK,
+		},
+		{
+			name:   "login too old",
+			locals: map[string]interface{}{"token_claims": &utils.AccessClaims{UserID: 7, AuthTime: stale}},
+			want:   fiber.StatusUnauthorized,
+		},
+		{
+			name:   "no auth_time",
+			locals: map[string]interface{}{"token_claims": &utils.AccessClaims{UserID: 7}},
+			want:   fiber.StatusUnauthorized,
+		},
+		{
+			// API key dengan scope users:write tidak boleh melewati step-up
+			name:   "api key",
+			locals: map[string]interface{}{"api_key": &store.APIKey{ID: 1, UserID: 7}},
+			want:   fiber.StatusForbidden,
+		},
+		{
+			// auth_time token impersonasi disalin dari sesi admin
+			name: "impersonation token",
+			locals: map[string]interface{}{
+				"token_claims": &utils.AccessClaims{UserID: 7, AuthTime: fresh, Actor: &utils.ActorClaim{UserID: 1}},
+				"actor_id":     1,
+			},
+			want: fiber.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Put("/users/:id",
+				func(c *fiber.Ctx) error {
+					c.Locals("user_id", 7)
+					for k, v := range tt.locals {
+						c.Locals(k, v)
+					}
+					return c.Next()
+				},
+				RequireRecentAuth(10*time.Minute),
+				func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+			)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodPut, "/users/7", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package middleware
 
 import (
 	"database/sql"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/store"
 )
 
-// RoleMiddleware memeriksa apakah user punya role tertentu
+// RoleMiddleware memeriksa apakah user punya role tertentu, langsung atau diwarisi lewat parent role
 func RoleMiddleware(db *sql.DB, requiredRole string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userID, ok := c.Locals("user_id").(int)
@@ -16,30 +19,53 @@ func RoleMiddleware(db *sql.DB, requiredRole string) fiber.Handler {
 			})
 		}
 
-		var exists bool
-		err := db.QueryRow(`
-			SELECT EXISTS(
-				SELECT 1 FROM user_roles ur
-				JOIN roles r ON ur.role_id = r.id
-				WHERE ur.user_id = $1 AND r.name = $2
-			)`, userID, requiredRole).Scan(&exists)
+		principal, err := GetPrincipal(c)
 		if err != nil {
+			log.Printf("RoleMiddleware: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Database error",
 			})
 		}
 
-		if !exists {
+		if !principal.HasRole(requiredRole) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "Access denied: insufficient permissions",
 			})
 		}
 
+		// role yang diwajibkan 2FA (MFA_REQUIRED_ROLES) hanya bisa dipakai setelah 2FA aktif
+		if roleRequiresMFA(requiredRole) {
+			var enabled bool
+			err := db.QueryRow(`
+				SELECT EXISTS(SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)
+			`, userID).Scan(&enabled)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Database error",
+				})
+			}
+			if !enabled {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "Two-factor authentication is required for this role",
+					"code":  "MFA_ENROLLMENT_REQUIRED",
+				})
+			}
+		}
+
 		return c.Next()
 	}
 }
 
+func roleRequiresMFA(role string) bool {
+	for _, r := range config.GetList("MFA_REQUIRED_ROLES") {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // AdminOnly shortcut
 func AdminOnly(db *sql.DB) fiber.Handler {
-	return RoleMiddleware(db, "admin")
+	return RoleMiddleware(db, store.AdminRole)
 }
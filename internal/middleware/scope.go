@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/qwerius/gonuxt/internal/store"
+)
+
+// APIKeyHeaderName header alternatif untuk mengirim API key
+const APIKeyHeaderName = "X-API-Key"
+
+// RequireScope dipasang setelah AuthRequired. Request dengan API key harus punya scope
+// tersebut; request dengan sesi login (JWT / cookie) tidak dibatasi scope.
+// Setiap route di belakang AuthRequired wajib memasang RequireScope, RequireSession,
+// atau RejectAPIKey, karena tanpa salah satunya API key dengan scope apa pun bisa masuk.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, ok := c.Locals("api_key").(*store.APIKey)
+		if !ok {
+			return c.Next()
+		}
+		if !key.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":         "error",
+				"message":        "API key does not have the required scope",
+				"required_scope": scope,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireSession menolak API key dan token impersonasi; untuk endpoint yang mengelola
+// kredensial (API key, 2FA, sesi) dan hanya boleh dipakai lewat login pemilik akun
+func RequireSession(c *fiber.Ctx) error {
+	if c.Locals("actor_id") != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This endpoint cannot be used while impersonating a user",
+		})
+	}
+	return RejectAPIKey(c)
+}
+
+// RejectAPIKey menolak API key tetapi menerima token impersonasi, mis. untuk mengakhiri impersonasi
+func RejectAPIKey(c *fiber.Ctx) error {
+	if c.Locals("api_key") != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "This endpoint cannot be used with an API key",
+		})
+	}
+	return c.Next()
+}
@@ -0,0 +1,57 @@
+package oauth
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+type githubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// fetchGitHubUser GitHub tidak memakai OIDC untuk login user; identitas diambil dari
+// /user, dan email primary yang terverifikasi dari /user/emails.
+// UserInfoURL berisi base URL API (default https://api.github.com).
+func (p *Provider) fetchGitHubUser(ctx context.Context, accessToken string) (*UserInfo, error) {
+	base := strings.TrimSuffix(p.UserInfoURL, "/")
+
+	var u githubUser
+	if err := getJSON(ctx, base+"/user", accessToken, &u); err != nil {
+		return nil, err
+	}
+
+	info := &UserInfo{
+		Subject: strconv.FormatInt(u.ID, 10),
+		Email:   u.Email,
+		Name:    u.Name,
+		Picture: u.AvatarURL,
+	}
+	if first, last, ok := strings.Cut(u.Name, " "); ok {
+		info.GivenName, info.FamilyName = first, last
+	} else {
+		info.GivenName = u.Name
+	}
+
+	var emails []githubEmail
+	if err := getJSON(ctx, base+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+	for _, e := range emails {
+		if e.Primary {
+			info.Email, info.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+	return info, nil
+}
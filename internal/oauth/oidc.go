@@ -0,0 +1,287 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWKS provider di-fetch ulang paling cepat setiap jwksMinRefresh saat ada kid baru
+const jwksMinRefresh = time.Minute
+
+// discoveryDocument sebagian isi /.well-known/openid-configuration
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// discover mengisi endpoint OIDC yang belum dikonfigurasi dari discovery document.
+// Gagal fetch tidak di-cache supaya dicoba lagi di request berikutnya.
+func (p *Provider) discover(ctx context.Context) error {
+	if p.Type != TypeOIDC {
+		return nil
+	}
+
+	p.discoverMu.Lock()
+	defer p.discoverMu.Unlock()
+
+	if p.discovered || (p.AuthURL != "" && p.TokenURL != "" && p.JWKSURL != "") {
+		p.discovered = true
+		return nil
+	}
+
+	var doc discoveryDocument
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, wellKnown, "", &doc); err != nil {
+		return fmt.Errorf("oidc discovery for %s: %w", p.Name, err)
+	}
+
+	fill := func(dst *string, v string) {
+		if *dst == "" {
+			*dst = v
+		}
+	}
+	fill(&p.AuthURL, doc.AuthorizationEndpoint)
+	fill(&p.TokenURL, doc.TokenEndpoint)
+	fill(&p.UserInfoURL, doc.UserinfoEndpoint)
+	fill(&p.JWKSURL, doc.JWKSURI)
+	// Microsoft multi-tenant mengembalikan issuer berisi placeholder {tenantid}
+	if doc.Issuer != "" {
+		p.Issuer = doc.Issuer
+	}
+
+	if p.AuthURL == "" || p.TokenURL == "" || p.JWKSURL == "" {
+		return fmt.Errorf("oidc discovery for %s: missing endpoints", p.Name)
+	}
+	p.discovered = true
+	return nil
+}
+
+// idTokenClaims claim ID token yang dipakai
+type idTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // bool, atau string "true" di beberapa IdP
+	Name          string      `json:"name"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	Picture       string      `json:"picture"`
+	Locale        string      `json:"locale"`
+	Nonce         string      `json:"nonce"`
+	AZP           string      `json:"azp"`
+	TenantID      string      `json:"tid"`
+	jwt.RegisteredClaims
+}
+
+func (p *Provider) fetchOIDCUser(ctx context.Context, tok *Token, nonce string) (*UserInfo, error) {
+	if tok.IDToken == "" {
+		return nil, errors.New("oidc: no id_token in token response")
+	}
+
+	claims, err := p.verifyIDToken(ctx, tok.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &UserInfo{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Picture:       claims.Picture,
+		Locale:        claims.Locale,
+	}
+
+	// sebagian IdP hanya menaruh profil lengkap di userinfo endpoint
+	if (info.Email == "" || info.Name == "") && p.UserInfoURL != "" {
+		var extra idTokenClaims
+		if err := getJSON(ctx, p.UserInfoURL, tok.AccessToken, &extra); err != nil {
+			return nil, fmt.Errorf("oidc userinfo: %w", err)
+		}
+		if extra.Subject != info.Subject {
+			return nil, errors.New("oidc userinfo: subject does not match id_token")
+		}
+		if info.Email == "" {
+			info.Email = extra.Email
+			info.EmailVerified = isTrue(extra.EmailVerified)
+		}
+		if info.Name == "" {
+			info.Name, info.GivenName, info.FamilyName = extra.Name, extra.GivenName, extra.FamilyName
+		}
+		if info.Picture == "" {
+			info.Picture = extra.Picture
+		}
+	}
+	return info, nil
+}
+
+// verifyIDToken memverifikasi tanda tangan ID token terhadap JWKS provider
+// beserta iss, aud (= client_id), azp, exp, iat, dan nonce
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.jwks.key(ctx, p.JWKSURL, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "EdDSA"}),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id_token: %w", err)
+	}
+
+	issuer := strings.ReplaceAll(p.Issuer, "{tenantid}", claims.TenantID)
+	if claims.Issuer != issuer && !slices.Contains(p.IssuerAliases, claims.Issuer) {
+		return nil, fmt.Errorf("oidc: unexpected issuer %q", claims.Issuer)
+	}
+	if len(claims.Audience) > 1 && claims.AZP != p.ClientID {
+		return nil, errors.New("oidc: id_token azp does not match client_id")
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, errors.New("oidc: id_token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: id_token has no subject")
+	}
+	return claims, nil
+}
+
+func isTrue(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return strings.EqualFold(b, "true")
+	}
+	return false
+}
+
+// jwksCache public key provider per kid
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key mengambil public key untuk kid; JWKS di-fetch ulang jika kid belum dikenal (rotasi kunci)
+func (c *jwksCache) key(ctx context.Context, jwksURL, kid string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	if time.Since(c.fetchedAt) < jwksMinRefresh {
+		return nil, fmt.Errorf("oidc: unknown key id %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(ctx, jwksURL, "", &set); err != nil {
+		return nil, fmt.Errorf("oidc: fetch jwks: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if k, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = k
+		}
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown key id %q", kid)
+}
+
+// lookup tanpa kid hanya berhasil jika JWKS berisi tepat satu kunci
+func (c *jwksCache) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	dec := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := dec.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
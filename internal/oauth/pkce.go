@@ -0,0 +1,38 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// PKCE code_verifier dan code_challenge (S256, RFC 7636)
+type PKCE struct {
+	Verifier  string
+	Challenge string
+}
+
+// NewPKCE membuat verifier acak 43 karakter beserta challenge-nya
+func NewPKCE() (PKCE, error) {
+	verifier, err := utils.RandomToken(32)
+	if err != nil {
+		return PKCE{}, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return PKCE{Verifier: verifier, Challenge: base64.RawURLEncoding.EncodeToString(sum[:])}, nil
+}
+
+// AuthParams parameter PKCE untuk URL login
+func (p PKCE) AuthParams() url.Values {
+	return url.Values{
+		"code_challenge":        {p.Challenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// ExchangeParams parameter PKCE untuk token endpoint
+func (p PKCE) ExchangeParams() url.Values {
+	return url.Values{"code_verifier": {p.Verifier}}
+}
@@ -0,0 +1,354 @@
+// Package oauth registry provider login pihak ketiga (Google, GitHub, Microsoft,
+// Keycloak, atau issuer OpenID Connect apa pun lewat discovery document).
+//
+// Setiap provider dikonfigurasi dari env dengan awalan nama provider dalam huruf besar,
+// mis. GOOGLE_CLIENT_ID, GITHUB_CLIENT_SECRET, KEYCLOAK_ISSUER. Semua endpoint bisa
+// ditimpa (<NAME>_AUTH_URL, <NAME>_TOKEN_URL, <NAME>_USERINFO_URL, <NAME>_JWKS_URL)
+// supaya alurnya bisa dites terhadap IdP stub lokal.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/config"
+)
+
+// jenis provider
+const (
+	TypeOIDC   = "oidc"   // OpenID Connect: identitas diambil dari ID token yang diverifikasi
+	TypeGitHub = "github" // OAuth2 biasa, identitas dari GitHub REST API
+)
+
+var (
+	// ErrUnknownProvider provider tidak terdaftar / tidak dikonfigurasi
+	ErrUnknownProvider = errors.New("unknown OAuth provider")
+	// ErrNoEmail provider tidak mengembalikan email
+	ErrNoEmail = errors.New("OAuth provider did not return an email address")
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Provider satu identity provider
+type Provider struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+
+	// Issuer wajib untuk OIDC; endpoint yang kosong diisi dari discovery document
+	Issuer      string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	JWKSURL     string
+	// AuthParams parameter tambahan di URL login (mis. prompt=consent)
+	AuthParams url.Values
+	// IssuerAliases nilai iss lain di id_token yang juga diterima selain Issuer
+	IssuerAliases []string
+
+	discoverMu sync.Mutex
+	discovered bool
+	jwks       *jwksCache
+}
+
+// UserInfo identitas user dari provider
+type UserInfo struct {
+	Subject       string // id unik user di provider
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+	Locale        string
+}
+
+// Token response dari token endpoint
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// defaults provider bawaan; yang lain dianggap OIDC generik dan butuh <NAME>_ISSUER
+var defaults = map[string]*Provider{
+	"google": {
+		Type:        TypeOIDC,
+		Issuer:      "https://accounts.google.com",
+		AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:    "https://oauth2.googleapis.com/token",
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		JWKSURL:     "https://www.googleapis.com/oauth2/v3/certs",
+		Scopes:      []string{"openid", "email", "profile"},
+		AuthParams:  url.Values{"access_type": {"offline"}, "prompt": {"consent"}},
+		// id_token Google kadang memakai iss tanpa skema
+		IssuerAliases: []string{"accounts.google.com"},
+	},
+	"github": {
+		Type:        TypeGitHub,
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com",
+		Scopes:      []string{"read:user", "user:email"},
+	},
+	"microsoft": {
+		Type:   TypeOIDC,
+		Issuer: "https://login.microsoftonline.com/{tenant}/v2.0",
+		Scopes: []string{"openid", "email", "profile"},
+	},
+	"keycloak": {
+		Type:   TypeOIDC,
+		Scopes: []string{"openid", "email", "profile"},
+	},
+}
+
+// Registry daftar provider yang aktif, berdasarkan nama di URL (/oauth/:provider/...)
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// ProvidersFromConfig membaca OAUTH_PROVIDERS (default: semua provider bawaan).
+// Provider tanpa <NAME>_CLIENT_ID dilewati sehingga tidak muncul di registry.
+func ProvidersFromConfig() (*Registry, error) {
+	names := config.GetList("OAUTH_PROVIDERS")
+	if len(names) == 0 {
+		for name := range defaults {
+			names = append(names, name)
+		}
+	}
+
+	r := &Registry{providers: map[string]*Provider{}}
+	for _, name := range names {
+		name = strings.ToLower(name)
+		p, err := providerFromConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			r.providers[name] = p
+		}
+	}
+	return r, nil
+}
+
+// Get provider berdasarkan nama
+func (r *Registry) Get(name string) (*Provider, error) {
+	p, ok := r.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names nama semua provider aktif, urut alfabet
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func providerFromConfig(name string) (*Provider, error) {
+	env := func(key string) string {
+		return config.Get(strings.ToUpper(name) + "_" + key)
+	}
+
+	clientID := env("CLIENT_ID")
+	if clientID == "" {
+		return nil, nil
+	}
+
+	def, builtin := defaults[name]
+	if !builtin {
+		def = &Provider{Type: TypeOIDC, Scopes: []string{"openid", "email", "profile"}}
+	}
+	p := &Provider{
+		Name:         name,
+		Type:         def.Type,
+		ClientID:     clientID,
+		ClientSecret: env("CLIENT_SECRET"),
+		RedirectURI:  env("REDIRECT_URI"),
+		Scopes:       def.Scopes,
+		Issuer:       def.Issuer,
+		AuthURL:      def.AuthURL,
+		TokenURL:     def.TokenURL,
+		UserInfoURL:  def.UserInfoURL,
+		JWKSURL:      def.JWKSURL,
+		AuthParams:   def.AuthParams,
+	}
+	override := func(dst *string, key string) {
+		if v := env(key); v != "" {
+			*dst = v
+		}
+	}
+	override(&p.Type, "TYPE")
+	override(&p.Issuer, "ISSUER")
+	override(&p.AuthURL, "AUTH_URL")
+	override(&p.TokenURL, "TOKEN_URL")
+	override(&p.UserInfoURL, "USERINFO_URL")
+	override(&p.JWKSURL, "JWKS_URL")
+	if scopes := config.GetList(strings.ToUpper(name) + "_SCOPES"); len(scopes) > 0 {
+		p.Scopes = scopes
+	}
+	// alias issuer bawaan tidak berlaku jika <NAME>_ISSUER diganti
+	if p.Issuer == def.Issuer {
+		p.IssuerAliases = def.IssuerAliases
+	}
+
+	// Microsoft: tenant bisa "common", "organizations", atau id tenant
+	if strings.Contains(p.Issuer, "{tenant}") {
+		tenant := env("TENANT")
+		if tenant == "" {
+			tenant = "common"
+		}
+		p.Issuer = strings.ReplaceAll(p.Issuer, "{tenant}", tenant)
+	}
+
+	switch p.Type {
+	case TypeOIDC:
+		if p.Issuer == "" {
+			return nil, fmt.Errorf("oauth: %s_ISSUER is required for provider %q", strings.ToUpper(name), name)
+		}
+		p.jwks = &jwksCache{}
+	case TypeGitHub:
+	default:
+		return nil, fmt.Errorf("oauth: unknown type %q for provider %q", p.Type, name)
+	}
+	return p, nil
+}
+
+// AuthCodeURL URL halaman login provider
+func (p *Provider) AuthCodeURL(ctx context.Context, redirectURI string, extra url.Values) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	for k, v := range p.AuthParams {
+		params[k] = v
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + params.Encode(), nil
+}
+
+// Exchange menukar authorization code dengan token
+func (p *Provider) Exchange(ctx context.Context, code, redirectURI string, extra url.Values) (*Token, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("client_id", p.ClientID)
+	values.Set("client_secret", p.ClientSecret)
+	values.Set("redirect_uri", redirectURI)
+	for k, v := range extra {
+		values[k] = v
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tok Token
+	err = doJSON(req, &tok)
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token exchange: %s: %s", tok.Error, tok.ErrorDescription)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("token exchange: no access_token in response")
+	}
+	return &tok, nil
+}
+
+// FetchUser identitas user dari token. Untuk OIDC, ID token diverifikasi
+// (tanda tangan terhadap JWKS provider, iss, aud, exp); nonce dicek jika tidak kosong.
+func (p *Provider) FetchUser(ctx context.Context, tok *Token, nonce string) (*UserInfo, error) {
+	var (
+		info *UserInfo
+		err  error
+	)
+	switch p.Type {
+	case TypeGitHub:
+		info, err = p.fetchGitHubUser(ctx, tok.AccessToken)
+	default:
+		info, err = p.fetchOIDCUser(ctx, tok, nonce)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Email == "" {
+		return nil, ErrNoEmail
+	}
+	return info, nil
+}
+
+// getJSON GET dengan bearer token (opsional) dan decode response JSON
+func getJSON(ctx context.Context, rawURL, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	// body tetap di-decode walau status error, supaya field "error" dari provider terbaca
+	decodeErr := json.Unmarshal(body, out)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: unexpected status %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+	return decodeErr
+}
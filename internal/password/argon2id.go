@@ -0,0 +1,129 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qwerius/gonuxt/internal/config"
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params parameter argon2id (memory dalam KiB)
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params mengikuti rekomendasi OWASP (m=64MiB, t=3, p=2)
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2ParamsFromConfig membaca ARGON2_MEMORY, ARGON2_ITERATIONS, ARGON2_PARALLELISM,
+// ARGON2_SALT_LENGTH, ARGON2_KEY_LENGTH; nilai kosong memakai default
+func Argon2ParamsFromConfig() Argon2Params {
+	d := DefaultArgon2Params
+	return Argon2Params{
+		Memory:      uint32(config.GetInt("ARGON2_MEMORY", int(d.Memory))),
+		Iterations:  uint32(config.GetInt("ARGON2_ITERATIONS", int(d.Iterations))),
+		Parallelism: uint8(config.GetInt("ARGON2_PARALLELISM", int(d.Parallelism))),
+		SaltLength:  uint32(config.GetInt("ARGON2_SALT_LENGTH", int(d.SaltLength))),
+		KeyLength:   uint32(config.GetInt("ARGON2_KEY_LENGTH", int(d.KeyLength))),
+	}
+}
+
+var errInvalidArgon2Hash = errors.New("password: invalid argon2id hash")
+
+// Argon2idHasher hash dalam format PHC:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt base64>$<hash base64>
+type Argon2idHasher struct {
+	Params Argon2Params
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, salt, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return p.Memory != h.Params.Memory ||
+		p.Iterations != h.Params.Iterations ||
+		p.Parallelism != h.Params.Parallelism ||
+		p.KeyLength != h.Params.KeyLength ||
+		uint32(len(salt)) != h.Params.SaltLength
+}
+
+func decodeArgon2id(encoded string) (p Argon2Params, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	// argon2.IDKey panic jika t atau p bernilai 0
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+	// key kosong membuat perbandingan dua slice kosong selalu cocok
+	if len(salt) == 0 || len(key) == 0 {
+		return p, nil, nil, errInvalidArgon2Hash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
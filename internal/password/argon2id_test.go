@@ -0,0 +1,56 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+// parameter kecil supaya test cepat
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	h := &Argon2idHasher{Params: testArgon2Params}
+
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := h.Verify("correct horse", encoded); err != nil || !ok {
+		t.Fatalf("Verify correct password = %v, %v", ok, err)
+	}
+	if ok, err := h.Verify("wrong horse", encoded); err != nil || ok {
+		t.Fatalf("Verify wrong password = %v, %v", ok, err)
+	}
+	if h.NeedsRehash(encoded) {
+		t.Fatal("NeedsRehash true for hash with current params")
+	}
+}
+
+func TestDecodeArgon2idRejectsInvalidHashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"wrong algorithm", "$argon2i$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{"wrong version", "$argon2id$v=16$m=65536,t=3,p=2$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{"missing part", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ"},
+		{"bad params", "$argon2id$v=19$m=65536,t=x,p=2$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{"zero iterations", "$argon2id$v=19$m=65536,t=0,p=2$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{"zero parallelism", "$argon2id$v=19$m=65536,t=3,p=0$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{"bad salt encoding", "$argon2id$v=19$m=65536,t=3,p=2$!!!$aGFzaGhhc2g"},
+		{"empty salt", "$argon2id$v=19$m=65536,t=3,p=2$$aGFzaGhhc2g"},
+		{"empty key", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$"},
+	}
+
+	h := &Argon2idHasher{Params: testArgon2Params}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2id(tt.encoded); !errors.Is(err, errInvalidArgon2Hash) {
+				t.Fatalf("decodeArgon2id error = %v, want errInvalidArgon2Hash", err)
+			}
+			if ok, err := h.Verify("any password", tt.encoded); ok || err == nil {
+				t.Fatalf("Verify = %v, %v; want false with error", ok, err)
+			}
+		})
+	}
+}
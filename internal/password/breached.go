@@ -0,0 +1,134 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qwerius/gonuxt/internal/config"
+)
+
+// BreachedCorpus daftar hash SHA-1 password yang pernah bocor, disimpan di memori
+// sebagai array byte berukuran tetap supaya pencarian cukup binary search.
+//
+// Format file: satu hash per baris, hex SHA-1 (boleh hanya prefix, mis. 16 karakter
+// pertama, asal panjangnya sama untuk semua baris), boleh diikuti ":count" seperti
+// dump HIBP. Baris kosong dan baris diawali "#" diabaikan.
+type BreachedCorpus struct {
+	width   int    // panjang satu entry dalam byte
+	entries []byte // len(entries) = width * Len()
+}
+
+var (
+	breachedMu sync.RWMutex
+	breached   *BreachedCorpus
+)
+
+// LoadBreachedCorpus membaca file corpus dari path
+func LoadBreachedCorpus(path string) (*BreachedCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &BreachedCorpus{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+
+		b, err := hex.DecodeString(text)
+		if err != nil || len(b) == 0 || len(b) > sha1.Size {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 prefix %q", path, line, text)
+		}
+		if c.width == 0 {
+			c.width = len(b)
+		} else if len(b) != c.width {
+			return nil, fmt.Errorf("%s:%d: prefix length %d differs from %d", path, line, len(b), c.width)
+		}
+		c.entries = append(c.entries, b...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// file seharusnya sudah terurut; diurutkan ulang kalau belum
+	if !sort.IsSorted(c) {
+		sort.Sort(c)
+	}
+	return c, nil
+}
+
+// Contains true jika SHA-1 password ada di corpus
+func (c *BreachedCorpus) Contains(password string) bool {
+	if c == nil || c.width == 0 {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	key := sum[:c.width]
+
+	i := sort.Search(c.Len(), func(i int) bool {
+		return bytes.Compare(c.at(i), key) >= 0
+	})
+	return i < c.Len() && bytes.Equal(c.at(i), key)
+}
+
+func (c *BreachedCorpus) at(i int) []byte {
+	return c.entries[i*c.width : (i+1)*c.width]
+}
+
+// Len, Less, Swap untuk sort.Interface
+func (c *BreachedCorpus) Len() int { return len(c.entries) / max(c.width, 1) }
+
+func (c *BreachedCorpus) Less(i, j int) bool { return bytes.Compare(c.at(i), c.at(j)) < 0 }
+
+func (c *BreachedCorpus) Swap(i, j int) {
+	tmp := make([]byte, c.width)
+	copy(tmp, c.at(i))
+	copy(c.at(i), c.at(j))
+	copy(c.at(j), tmp)
+}
+
+// UseBreachedCorpus memasang corpus global yang dipakai IsBreached (nil = nonaktif)
+func UseBreachedCorpus(c *BreachedCorpus) {
+	breachedMu.Lock()
+	breached = c
+	breachedMu.Unlock()
+}
+
+// LoadBreachedCorpusFromConfig memuat file dari BREACHED_PASSWORDS_FILE saat startup.
+// Jika env kosong, pengecekan password bocor dilewati.
+func LoadBreachedCorpusFromConfig() (int, error) {
+	path := config.Get("BREACHED_PASSWORDS_FILE")
+	if path == "" {
+		return 0, nil
+	}
+
+	c, err := LoadBreachedCorpus(path)
+	if err != nil {
+		return 0, err
+	}
+	UseBreachedCorpus(c)
+	return c.Len(), nil
+}
+
+// IsBreached true jika password ada di corpus global
+func IsBreached(password string) bool {
+	breachedMu.RLock()
+	defer breachedMu.RUnlock()
+	return breached.Contains(password)
+}
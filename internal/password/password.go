@@ -0,0 +1,95 @@
+// Package password menangani hashing password dengan hasher yang bisa diganti.
+// Hash disimpan dalam format PHC ($argon2id$...) atau modular crypt bcrypt ($2a$...),
+// sehingga hash lama tetap bisa diverifikasi dan di-upgrade otomatis saat login.
+package password
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/qwerius/gonuxt/internal/config"
+)
+
+// ErrUnknownHash format hash tidak dikenali hasher mana pun
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// Hasher satu algoritma hashing password
+type Hasher interface {
+	// Hash membuat hash baru (lengkap dengan salt & parameter) dari password
+	Hash(password string) (string, error)
+	// Verify membandingkan password dengan hash yang tersimpan
+	Verify(password, encoded string) (bool, error)
+	// Recognizes true jika encoded dibuat oleh algoritma ini
+	Recognizes(encoded string) bool
+	// NeedsRehash true jika encoded dibuat dengan parameter yang berbeda dari konfigurasi sekarang
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	once      sync.Once
+	current   Hasher   // dipakai untuk hash baru
+	verifiers []Hasher // semua hasher yang bisa memverifikasi hash lama
+	dummyHash string
+)
+
+// setup membaca konfigurasi saat pertama dipakai (setelah config.Load di main)
+func setup() {
+	once.Do(func() {
+		argon := &Argon2idHasher{Params: Argon2ParamsFromConfig()}
+		bc := &BcryptHasher{Cost: config.GetInt("BCRYPT_COST", DefaultBcryptCost)}
+
+		switch strings.ToLower(config.Get("PASSWORD_HASHER")) {
+		case "bcrypt":
+			current = bc
+		default:
+			current = argon
+		}
+		verifiers = []Hasher{argon, bc}
+
+		dummyHash, _ = current.Hash("dummy-password")
+	})
+}
+
+// Use mengganti hasher default dan daftar verifier (mis. untuk parameter khusus)
+func Use(def Hasher, others ...Hasher) {
+	setup()
+	current = def
+	verifiers = append([]Hasher{def}, others...)
+	dummyHash, _ = current.Hash("dummy-password")
+}
+
+// Hash membuat hash password dengan hasher default
+func Hash(password string) (string, error) {
+	setup()
+	return current.Hash(password)
+}
+
+// Verify mengecek password terhadap hash tersimpan. needsRehash true jika password
+// benar tetapi hash-nya memakai algoritma/parameter lama dan sebaiknya di-hash ulang.
+func Verify(password, encoded string) (ok bool, needsRehash bool, err error) {
+	setup()
+
+	for _, h := range verifiers {
+		if !h.Recognizes(encoded) {
+			continue
+		}
+
+		ok, err = h.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		needsRehash = h != current || current.NeedsRehash(encoded)
+		return true, needsRehash, nil
+	}
+
+	return false, false, ErrUnknownHash
+}
+
+// SimulateVerify menjalankan verifikasi terhadap hash dummy supaya waktu respon
+// untuk akun yang tidak ada / terkunci sama dengan password salah
+func SimulateVerify(password string) {
+	setup()
+	_, _ = current.Verify(password, dummyHash)
+}
@@ -0,0 +1,187 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/qwerius/gonuxt/internal/config"
+)
+
+// kelas karakter yang dikenal policy
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// panjang minimal potongan email/nama yang dianggap "personal info"
+const minPersonalTokenLen = 3
+
+// Violation satu aturan policy yang dilanggar
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// Policy aturan password baru (register, reset, ganti password oleh admin)
+type Policy struct {
+	MinLength       int
+	MaxLength       int      // dalam byte; bcrypt hanya memakai 72 byte pertama
+	MinClasses      int      // minimal jumlah kelas karakter berbeda
+	RequiredClasses []string // kelas yang wajib ada (lower, upper, digit, symbol)
+	ForbidPersonal  bool     // tolak password yang memuat bagian email / nama
+	CheckBreached   bool     // tolak password yang ada di corpus bocoran
+}
+
+// PolicyFromConfig membaca PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH, PASSWORD_MIN_CLASSES,
+// PASSWORD_REQUIRED_CLASSES, PASSWORD_FORBID_PERSONAL_INFO, PASSWORD_CHECK_BREACHED
+func PolicyFromConfig() Policy {
+	p := Policy{
+		MinLength:       config.GetInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:       config.GetInt("PASSWORD_MAX_LENGTH", 72),
+		MinClasses:      config.GetInt("PASSWORD_MIN_CLASSES", 2),
+		RequiredClasses: config.GetList("PASSWORD_REQUIRED_CLASSES"),
+		ForbidPersonal:  true,
+		CheckBreached:   true,
+	}
+	if config.Get("PASSWORD_FORBID_PERSONAL_INFO") != "" {
+		p.ForbidPersonal = config.GetBool("PASSWORD_FORBID_PERSONAL_INFO")
+	}
+	if config.Get("PASSWORD_CHECK_BREACHED") != "" {
+		p.CheckBreached = config.GetBool("PASSWORD_CHECK_BREACHED")
+	}
+	return p
+}
+
+// Validate mengecek password terhadap policy. personal berisi email / nama user
+// (boleh kosong) yang tidak boleh muncul di dalam password.
+func (p Policy) Validate(password string, personal ...string) []Violation {
+	var out []Violation
+
+	if password == "" {
+		return []Violation{{Code: "required", Message: "password is required"}}
+	}
+
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		out = append(out, Violation{
+			Code:    "too_short",
+			Message: fmt.Sprintf("must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		out = append(out, Violation{
+			Code:    "too_long",
+			Message: fmt.Sprintf("must be at most %d bytes", p.MaxLength),
+		})
+	}
+
+	classes := characterClasses(password)
+	for _, class := range p.RequiredClasses {
+		class = strings.ToLower(class)
+		if !classes[class] {
+			out = append(out, Violation{
+				Code:    "missing_" + class,
+				Message: fmt.Sprintf("must contain at least one %s character", classLabel(class)),
+			})
+		}
+	}
+	if len(classes) < p.MinClasses {
+		out = append(out, Violation{
+			Code: "too_few_classes",
+			Message: fmt.Sprintf(
+				"must mix at least %d of: lowercase, uppercase, digits, symbols", p.MinClasses,
+			),
+		})
+	}
+
+	if p.ForbidPersonal {
+		if token, ok := containsPersonalInfo(password, personal); ok {
+			out = append(out, Violation{
+				Code:    "contains_personal_info",
+				Message: fmt.Sprintf("must not contain your email or name (%q)", token),
+			})
+		}
+	}
+
+	if p.CheckBreached && IsBreached(password) {
+		out = append(out, Violation{
+			Code:    "breached",
+			Message: "this password has appeared in a data breach, choose another one",
+		})
+	}
+
+	return out
+}
+
+// Validate mengecek password dengan policy dari konfigurasi
+func Validate(password string, personal ...string) []Violation {
+	return PolicyFromConfig().Validate(password, personal...)
+}
+
+func characterClasses(s string) map[string]bool {
+	classes := map[string]bool{}
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			classes[ClassLower] = true
+		case unicode.IsUpper(r):
+			classes[ClassUpper] = true
+		case unicode.IsDigit(r):
+			classes[ClassDigit] = true
+		default:
+			classes[ClassSymbol] = true
+		}
+	}
+	return classes
+}
+
+func classLabel(class string) string {
+	switch class {
+	case ClassLower:
+		return "lowercase"
+	case ClassUpper:
+		return "uppercase"
+	case ClassDigit:
+		return "digit"
+	case ClassSymbol:
+		return "symbol"
+	}
+	return class
+}
+
+// containsPersonalInfo memecah email/nama menjadi potongan kata (bagian user email, nama depan, ...)
+// lalu mengecek apakah salah satunya muncul di password (case-insensitive)
+func containsPersonalInfo(password string, personal []string) (string, bool) {
+	lower := strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(lower, value) {
+			return value, true
+		}
+		// domain email (gmail, com, ...) tidak dihitung
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			value = value[:at]
+		}
+
+		tokens := strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, token := range tokens {
+			if utf8.RuneCountInString(token) < minPersonalTokenLen {
+				continue
+			}
+			if strings.Contains(lower, token) {
+				return token, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,220 @@
+// Package policy engine kebijakan akses berbasis atribut (ABAC). Kebijakan dideklarasikan per
+// resource + action di file JSON dan dievaluasi tanpa bergantung pada HTTP, sehingga bisa
+// dipanggil langsung dari middleware.Authorize maupun dari kode lain.
+//
+// Satu request dievaluasi terhadap semua kebijakan yang berlaku (resource & action cocok, dan
+// jika kebijakan punya "fields", request mengubah salah satu field tersebut). Semua kebijakan
+// yang berlaku harus mengizinkan; jika tidak ada satu pun yang berlaku, request ditolak.
+// Sebuah kebijakan mengizinkan jika salah satu kondisi di "allow" terpenuhi, dan sebuah
+// kondisi terpenuhi jika semua isinya terpenuhi:
+//
+//	{ "owner": true }                     subject adalah pemilik resource
+//	{ "role": "admin" }                   subject punya role (termasuk warisan)
+//	{ "permission": "users:write" }       subject punya permission
+//	{ "attributes": { "is_verified": false } }  atribut resource bernilai sama
+package policy
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+//go:embed policies.json
+var defaultPolicies []byte
+
+// ActionAny cocok dengan semua action
+const ActionAny = "*"
+
+// Condition satu syarat di "allow"; field yang kosong tidak dicek
+type Condition struct {
+	Owner      bool                   `json:"owner,omitempty"`
+	Role       string                 `json:"role,omitempty"`
+	Permission string                 `json:"permission,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Policy satu aturan untuk resource + action
+type Policy struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Resource    string      `json:"resource"`
+	Actions     []string    `json:"actions"`
+	Fields      []string    `json:"fields,omitempty"`
+	Allow       []Condition `json:"allow"`
+}
+
+// Subject pelaku request
+type Subject struct {
+	ID          int
+	Roles       []string
+	Permissions []string
+}
+
+// Resource objek yang diakses
+type Resource struct {
+	Type    string
+	OwnerID int // 0 jika resource tidak punya pemilik
+	Attrs   map[string]interface{}
+}
+
+// Request satu permintaan akses. Fields berisi field yang dikirim/diubah (untuk create/update).
+type Request struct {
+	Subject  Subject
+	Action   string
+	Resource Resource
+	Fields   []string
+}
+
+// Decision hasil evaluasi; Policy berisi kebijakan yang menolak jika Allowed false
+type Decision struct {
+	Allowed bool
+	Policy  string
+	Reason  string
+}
+
+// Engine kumpulan kebijakan yang sudah divalidasi
+type Engine struct {
+	policies []Policy
+}
+
+type document struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Default kebijakan bawaan (policies.json yang di-embed)
+func Default() (*Engine, error) {
+	return Parse(defaultPolicies)
+}
+
+// LoadFile memuat kebijakan dari file JSON; path kosong berarti kebijakan bawaan
+func LoadFile(path string) (*Engine, error) {
+	if path == "" {
+		return Default()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse membaca dan memvalidasi dokumen kebijakan; key yang tidak dikenal ditolak
+// supaya salah ketik tidak diam-diam melonggarkan akses
+func Parse(data []byte) (*Engine, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var doc document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+
+	names := make(map[string]bool, len(doc.Policies))
+	for i, p := range doc.Policies {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("policy #%d %q: %w", i, p.Name, err)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("policy %q: duplicate name", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return &Engine{policies: doc.Policies}, nil
+}
+
+func (p Policy) validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("name is required")
+	case p.Resource == "":
+		return errors.New("resource is required")
+	case len(p.Actions) == 0:
+		return errors.New("actions is required")
+	case len(p.Allow) == 0:
+		return errors.New("allow is required")
+	}
+	for i, c := range p.Allow {
+		if !c.Owner && c.Role == "" && c.Permission == "" && len(c.Attributes) == 0 {
+			return fmt.Errorf("allow #%d: empty condition", i)
+		}
+	}
+	return nil
+}
+
+// Evaluate memutuskan apakah request diizinkan
+func (e *Engine) Evaluate(req Request) Decision {
+	applied := 0
+	for _, p := range e.policies {
+		if !p.appliesTo(req) {
+			continue
+		}
+		applied++
+		if !p.allows(req) {
+			return Decision{Policy: p.Name, Reason: "denied by policy " + p.Name}
+		}
+	}
+	if applied == 0 {
+		return Decision{Reason: fmt.Sprintf("no policy for %s:%s", req.Resource.Type, req.Action)}
+	}
+	return Decision{Allowed: true}
+}
+
+func (p Policy) appliesTo(req Request) bool {
+	if p.Resource != req.Resource.Type {
+		return false
+	}
+	if !contains(p.Actions, req.Action) && !contains(p.Actions, ActionAny) {
+		return false
+	}
+	if len(p.Fields) == 0 {
+		return true
+	}
+	for _, f := range req.Fields {
+		if contains(p.Fields, f) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) allows(req Request) bool {
+	for _, c := range p.Allow {
+		if c.matches(req) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Condition) matches(req Request) bool {
+	if c.Owner && (req.Subject.ID == 0 || req.Resource.OwnerID != req.Subject.ID) {
+		return false
+	}
+	if c.Role != "" && !contains(req.Subject.Roles, c.Role) {
+		return false
+	}
+	if c.Permission != "" && !contains(req.Subject.Permissions, c.Permission) {
+		return false
+	}
+	for k, want := range c.Attributes {
+		got, ok := req.Resource.Attrs[k]
+		// angka dari JSON selalu float64, jadi dibandingkan dalam bentuk teks
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
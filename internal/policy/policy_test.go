@@ -0,0 +1,184 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			doc: `{"policies": [
+				{"name": "a", "resource": "profile", "actions": ["update"], "allow": [{"owner": true}]}
+			]}`,
+		},
+		{
+			name:    "unknown key",
+			doc:     `{"policies": [{"name": "a", "resource": "profile", "actions": ["update"], "alow": [{"owner": true}]}]}`,
+			wantErr: `unknown field "alow"`,
+		},
+		{
+			name:    "unknown condition key",
+			doc:     `{"policies": [{"name": "a", "resource": "profile", "actions": ["update"], "allow": [{"roles": "admin"}]}]}`,
+			wantErr: `unknown field "roles"`,
+		},
+		{
+			name:    "empty condition",
+			doc:     `{"policies": [{"name": "a", "resource": "profile", "actions": ["update"], "allow": [{}]}]}`,
+			wantErr: "empty condition",
+		},
+		{
+			name:    "missing allow",
+			doc:     `{"policies": [{"name": "a", "resource": "profile", "actions": ["update"]}]}`,
+			wantErr: "allow is required",
+		},
+		{
+			name: "duplicate name",
+			doc: `{"policies": [
+				{"name": "a", "resource": "profile", "actions": ["update"], "allow": [{"owner": true}]},
+				{"name": "a", "resource": "user", "actions": ["update"], "allow": [{"role": "admin"}]}
+			]}`,
+			wantErr: "duplicate name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse: unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse: got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicies(t *testing.T) {
+	if _, err := Default(); err != nil {
+		t.Fatalf("Default: %v", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	engine, err := Parse([]byte(`{"policies": [
+		{
+			"name": "profile-owner-or-admin",
+			"resource": "profile",
+			"actions": ["create", "update", "delete"],
+			"allow": [{"owner": true}, {"role": "admin"}]
+		},
+		{
+			"name": "profile-verification-admin-only",
+			"resource": "profile",
+			"actions": ["create", "update"],
+			"fields": ["is_verified"],
+			"allow": [{"role": "admin"}]
+		},
+		{
+			"name": "user-owner-or-users-write",
+			"resource": "user",
+			"actions": ["*"],
+			"allow": [{"owner": true}, {"permission": "users:write"}]
+		}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := Subject{ID: 7}
+	admin := Subject{ID: 1, Roles: []string{"admin"}}
+	writer := Subject{ID: 2, Permissions: []string{"users:write"}}
+	profile := Resource{Type: "profile", OwnerID: 7}
+
+	tests := []struct {
+		name       string
+		req        Request
+		wantAllow  bool
+		wantPolicy string
+	}{
+		{
+			name: "no applicable policy denies",
+			req:  Request{Subject: admin, Action: "read", Resource: profile},
+		},
+		{
+			name: "unknown resource denies",
+			req:  Request{Subject: admin, Action: "update", Resource: Resource{Type: "invoice", OwnerID: 1}},
+		},
+		{
+			name:      "owner updates own profile",
+			req:       Request{Subject: owner, Action: "update", Resource: profile, Fields: []string{"nama"}},
+			wantAllow: true,
+		},
+		{
+			name:       "other user is not owner",
+			req:        Request{Subject: Subject{ID: 8}, Action: "update", Resource: profile},
+			wantPolicy: "profile-owner-or-admin",
+		},
+		{
+			name:      "role allows non-owner",
+			req:       Request{Subject: admin, Action: "delete", Resource: profile},
+			wantAllow: true,
+		},
+		{
+			name:       "field-scoped policy applies when field is sent",
+			req:        Request{Subject: owner, Action: "update", Resource: profile, Fields: []string{"nama", "is_verified"}},
+			wantPolicy: "profile-verification-admin-only",
+		},
+		{
+			name:      "field-scoped policy allows role",
+			req:       Request{Subject: admin, Action: "create", Resource: profile, Fields: []string{"is_verified"}},
+			wantAllow: true,
+		},
+		{
+			name:      "field-scoped policy skipped for other actions",
+			req:       Request{Subject: owner, Action: "delete", Resource: profile, Fields: []string{"is_verified"}},
+			wantAllow: true,
+		},
+		{
+			name:      "wildcard action and permission",
+			req:       Request{Subject: writer, Action: "update", Resource: Resource{Type: "user", OwnerID: 7}},
+			wantAllow: true,
+		},
+		{
+			name:       "anonymous subject is never owner",
+			req:        Request{Action: "update", Resource: Resource{Type: "user"}},
+			wantPolicy: "user-owner-or-users-write",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := engine.Evaluate(tt.req)
+			if d.Allowed != tt.wantAllow || d.Policy != tt.wantPolicy {
+				t.Fatalf("Evaluate = %+v, want allowed=%v policy=%q", d, tt.wantAllow, tt.wantPolicy)
+			}
+		})
+	}
+}
+
+func TestConditionAttributes(t *testing.T) {
+	c := Condition{Attributes: map[string]interface{}{"is_verified": false, "level": float64(2)}}
+
+	match := Request{Resource: Resource{Attrs: map[string]interface{}{"is_verified": false, "level": 2}}}
+	if !c.matches(match) {
+		t.Fatal("expected attributes to match")
+	}
+	mismatch := Request{Resource: Resource{Attrs: map[string]interface{}{"is_verified": true, "level": 2}}}
+	if c.matches(mismatch) {
+		t.Fatal("expected attributes not to match")
+	}
+	missing := Request{Resource: Resource{Attrs: map[string]interface{}{"level": 2}}}
+	if c.matches(missing) {
+		t.Fatal("expected missing attribute not to match")
+	}
+}
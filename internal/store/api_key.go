@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// APIKeyPrefix awalan semua API key, memudahkan secret scanner mengenali key yang bocor
+const APIKeyPrefix = "pat_"
+
+// jumlah byte acak untuk bagian prefix (disimpan plaintext, untuk lookup & tampilan)
+const apiKeyPrefixBytes = 6
+
+// Scope yang bisa diberikan ke API key. Setiap route di belakang AuthRequired wajib
+// memakai middleware.RequireScope dengan salah satu nilai ini, atau RequireSession / RejectAPIKey.
+const (
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopeRolesRead     = "roles:read"
+	ScopeRolesWrite    = "roles:write"
+	ScopeProfilesRead  = "profiles:read"
+	ScopeProfilesWrite = "profiles:write"
+	ScopeAuditLogsRead = "audit_logs:read"
+	ScopeOrgsRead      = "orgs:read"
+)
+
+// APIKeyScopes daftar semua scope yang valid
+var APIKeyScopes = []string{
+	ScopeUsersRead, ScopeUsersWrite,
+	ScopeRolesRead, ScopeRolesWrite,
+	ScopeProfilesRead, ScopeProfilesWrite,
+	ScopeAuditLogsRead,
+	ScopeOrgsRead,
+}
+
+var (
+	// ErrAPIKeyInvalid key tidak dikenal, dicabut, atau kadaluarsa
+	ErrAPIKeyInvalid = errors.New("invalid API key")
+	// ErrAPIKeyUnknownScope scope tidak ada di APIKeyScopes
+	ErrAPIKeyUnknownScope = errors.New("unknown API key scope")
+)
+
+// APIKeys store global, dipakai middleware.AuthRequired (di-set di RegisterRoutes)
+var APIKeys *APIKeyStore
+
+// APIKey metadata satu key (secret tidak pernah disimpan plaintext)
+type APIKey struct {
+	ID         int
+	UserID     int
+	Name       string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+}
+
+// HasScope true jika key diberi scope tersebut
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore menyimpan API key di tabel api_keys
+type APIKeyStore struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyStore(db *sql.DB) *APIKeyStore {
+	return &APIKeyStore{DB: db}
+}
+
+// IsAPIKey true jika token berformat API key (bukan JWT)
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Create membuat key baru; token lengkap (pat_<prefix>_<secret>) hanya dikembalikan sekali
+func (s *APIKeyStore) Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", ErrAPIKeyUnknownScope
+		}
+	}
+
+	b := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(b)
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &APIKey{UserID: userID, Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt}
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, secret_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, userID, name, prefix, utils.HashToken(secret), pq.Array(scopes), expiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, APIKeyPrefix + prefix + "_" + secret, nil
+}
+
+// List semua key aktif (belum dicabut) milik user, terbaru dulu
+func (s *APIKeyStore) List(ctx context.Context, userID int) ([]APIKey, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, COALESCE(last_used_ip, ''), created_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+			&expiresAt, &lastUsedAt, &k.LastUsedIP, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			k.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			k.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke mencabut key milik user; false jika key tidak ditemukan / sudah dicabut
+func (s *APIKeyStore) Revoke(ctx context.Context, userID, keyID int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, keyID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// Authenticate mencari key dari token lengkap dan memastikan masih berlaku
+func (s *APIKeyStore) Authenticate(ctx context.Context, token string) (*APIKey, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	if !IsAPIKey(token) || !ok || len(prefix) != apiKeyPrefixBytes*2 || secret == "" {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	var k APIKey
+	var secretHash string
+	var expiresAt sql.NullTime
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at
+		FROM api_keys
+		WHERE prefix = $1 AND revoked_at IS NULL
+	`, prefix).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &secretHash, pq.Array(&k.Scopes), &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secretHash), []byte(utils.HashToken(secret))) != 1 {
+		return nil, ErrAPIKeyInvalid
+	}
+	if expiresAt.Valid {
+		if time.Now().After(expiresAt.Time) {
+			return nil, ErrAPIKeyInvalid
+		}
+		k.ExpiresAt = &expiresAt.Time
+	}
+	return &k, nil
+}
+
+// Touch mencatat waktu & IP pemakaian terakhir. Ditulis paling sering sekali per menit
+// supaya key yang dipakai terus-menerus tidak membebani DB.
+func (s *APIKeyStore) Touch(ctx context.Context, keyID int, ip string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)
+	`, keyID, ip)
+	return err
+}
+
+func validScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/config"
+)
+
+// LockoutPolicy aturan lockout: setelah Threshold kali gagal, akun dikunci
+// selama Base, lalu dua kali lipat untuk setiap kegagalan berikutnya (maks Max).
+// Hitungan gagal mulai dari nol lagi jika kegagalan terakhir lebih lama dari Window.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// LockoutPolicyFromConfig membaca LOGIN_LOCKOUT_THRESHOLD, LOGIN_LOCKOUT_BASE, LOGIN_LOCKOUT_MAX,
+// LOGIN_LOCKOUT_WINDOW
+func LockoutPolicyFromConfig() LockoutPolicy {
+	return LockoutPolicy{
+		Threshold: config.GetInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		Base:      config.GetDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+		Max:       config.GetDuration("LOGIN_LOCKOUT_MAX", 24*time.Hour),
+		Window:    config.GetDuration("LOGIN_LOCKOUT_WINDOW", 24*time.Hour),
+	}
+}
+
+// LockDuration lama lockout setelah failures kali gagal berturut-turut (0 = belum dikunci)
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	d := p.Base
+	for i := p.Threshold; i < failures && d < p.Max; i++ {
+		d *= 2
+	}
+	if d > p.Max {
+		d = p.Max
+	}
+	return d
+}
+
+// LockoutStore menghitung gagal login per akun di tabel users
+type LockoutStore struct {
+	DB     *sql.DB
+	Policy LockoutPolicy
+}
+
+func NewLockoutStore(db *sql.DB) *LockoutStore {
+	return &LockoutStore{DB: db, Policy: LockoutPolicyFromConfig()}
+}
+
+// RecordFailure menambah hitungan gagal login dan mengunci akun jika melewati threshold.
+// Kegagalan setelah jeda lebih dari Policy.Window dihitung sebagai kegagalan pertama.
+// newlyLocked true hanya pada kegagalan yang pertama kali memicu lockout.
+func (s *LockoutStore) RecordFailure(ctx context.Context, userID int) (lockedUntil time.Time, newlyLocked bool, err error) {
+	var failures int
+	err = s.DB.QueryRowContext(ctx, `
+		UPDATE users
+		SET failed_login_count = CASE
+				WHEN $2::float8 > 0 AND (last_failed_login_at IS NULL
+					OR last_failed_login_at < NOW() - make_interval(secs => $2::float8)) THEN 1
+				ELSE failed_login_count + 1
+			END,
+			last_failed_login_at = NOW()
+		WHERE id = $1
+		RETURNING failed_login_count
+	`, userID, s.Policy.Window.Seconds()).Scan(&failures)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	d := s.Policy.LockDuration(failures)
+	if d == 0 {
+		return time.Time{}, false, nil
+	}
+
+	lockedUntil = time.Now().Add(d)
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE users SET locked_until = $1 WHERE id = $2`, lockedUntil, userID,
+	); err != nil {
+		return time.Time{}, false, err
+	}
+	return lockedUntil, failures == s.Policy.Threshold, nil
+}
+
+// Reset menghapus hitungan gagal login dan lockout (login sukses / unlock admin)
+func (s *LockoutStore) Reset(ctx context.Context, userID int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE users SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Metode login yang dicatat di login_events. Login OAuth memakai LoginMethodOAuth(provider).
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodMagicLink = "magic_link"
+	LoginMethodMFA       = "mfa"
+)
+
+// Alasan login gagal
+const (
+	LoginFailureUnknownEmail      = "unknown_email"
+	LoginFailureAccountLocked     = "account_locked"
+	LoginFailureInvalidPassword   = "invalid_password"
+	LoginFailureEmailNotVerified  = "email_not_verified"
+	LoginFailureInvalidMFACode    = "invalid_mfa_code"
+	LoginFailureOAuthLinkRequired = "oauth_link_required"
+)
+
+// LoginMethodOAuth metode login lewat provider OAuth, mis. "oauth:google"
+func LoginMethodOAuth(provider string) string {
+	return "oauth:" + provider
+}
+
+// LoginEvent satu percobaan login
+type LoginEvent struct {
+	ID            int64
+	UserID        int // 0 jika email tidak dikenal
+	Email         string
+	IP            string
+	UserAgent     string
+	Method        string
+	Success       bool
+	FailureReason string
+	CreatedAt     time.Time
+}
+
+// LoginEventFilter filter untuk List; nilai kosong berarti tidak difilter
+type LoginEventFilter struct {
+	UserID  int
+	Success *bool
+	Limit   int
+	Offset  int
+}
+
+// LoginEventStore menyimpan riwayat login di tabel login_events
+type LoginEventStore struct {
+	DB *sql.DB
+}
+
+func NewLoginEventStore(db *sql.DB) *LoginEventStore {
+	return &LoginEventStore{DB: db}
+}
+
+// Record menyimpan event. newDevice true jika login sukses ini berasal dari kombinasi
+// IP + user agent yang belum pernah dipakai login sukses oleh user tersebut
+// (login pertama kali tidak dianggap perangkat baru).
+func (s *LoginEventStore) Record(ctx context.Context, ev LoginEvent) (newDevice bool, err error) {
+	var userID sql.NullInt64
+	if ev.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(ev.UserID), Valid: true}
+	}
+	var reason sql.NullString
+	if !ev.Success {
+		reason = sql.NullString{String: ev.FailureReason, Valid: true}
+	}
+
+	if ev.Success && userID.Valid {
+		var hasLogins, seen bool
+		err = s.DB.QueryRowContext(ctx, `
+			SELECT
+				EXISTS(SELECT 1 FROM login_events WHERE user_id = $1 AND success),
+				EXISTS(SELECT 1 FROM login_events WHERE user_id = $1 AND success AND ip = $2 AND user_agent = $3)
+		`, userID, ev.IP, ev.UserAgent).Scan(&hasLogins, &seen)
+		if err != nil {
+			return false, err
+		}
+		newDevice = hasLogins && !seen
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO login_events (user_id, email, ip, user_agent, method, success, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, userID, ev.Email, ev.IP, ev.UserAgent, ev.Method, ev.Success, reason)
+	if err != nil {
+		return false, err
+	}
+	return newDevice, nil
+}
+
+// List event sesuai filter, terbaru dulu, beserta jumlah total untuk pagination
+func (s *LoginEventStore) List(ctx context.Context, f LoginEventFilter) ([]LoginEvent, int, error) {
+	var where []string
+	var args []interface{}
+	if f.UserID != 0 {
+		args = append(args, f.UserID)
+		where = append(where, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if f.Success != nil {
+		args = append(args, *f.Success)
+		where = append(where, fmt.Sprintf("success = $%d", len(args)))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM login_events "+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, COALESCE(user_id, 0), email, ip, user_agent, method, success, COALESCE(failure_reason, ''), created_at
+		FROM login_events
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, cond, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []LoginEvent{}
+	for rows.Next() {
+		var ev LoginEvent
+		if err := rows.Scan(&ev.ID, &ev.UserID, &ev.Email, &ev.IP, &ev.UserAgent,
+			&ev.Method, &ev.Success, &ev.FailureReason, &ev.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, ev)
+	}
+	return events, total, rows.Err()
+}
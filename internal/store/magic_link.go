@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+var (
+	// ErrMagicLinkInvalid token tidak dikenal, sudah dipakai, atau kadaluarsa
+	ErrMagicLinkInvalid = errors.New("invalid or expired sign-in link")
+	// ErrMagicLinkRateLimited terlalu banyak link diminta untuk email yang sama
+	ErrMagicLinkRateLimited = errors.New("too many sign-in links requested")
+)
+
+// MagicLinkStore menyimpan hash token login tanpa password di tabel magic_link_tokens
+type MagicLinkStore struct {
+	DB *sql.DB
+	// TTL masa berlaku link (MAGIC_LINK_TTL, default 15 menit)
+	TTL time.Duration
+	// MaxPerWindow jumlah link maksimal per akun dalam Window
+	// (MAGIC_LINK_MAX_PER_WINDOW, MAGIC_LINK_WINDOW; default 3 per jam)
+	MaxPerWindow int
+	Window       time.Duration
+}
+
+func NewMagicLinkStore(db *sql.DB) *MagicLinkStore {
+	return &MagicLinkStore{
+		DB:           db,
+		TTL:          config.GetDuration("MAGIC_LINK_TTL", 15*time.Minute),
+		MaxPerWindow: config.GetInt("MAGIC_LINK_MAX_PER_WINDOW", 3),
+		Window:       config.GetDuration("MAGIC_LINK_WINDOW", time.Hour),
+	}
+}
+
+// Create membuat link baru untuk user; link lama yang belum dipakai langsung tidak berlaku.
+// Batas per akun dihitung dari tabel supaya berlaku di semua instance.
+func (s *MagicLinkStore) Create(ctx context.Context, userID int, ip string) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// kunci baris user supaya permintaan paralel tidak melewati batas
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return "", err
+	}
+
+	var recent int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM magic_link_tokens WHERE user_id = $1 AND created_at > $2
+	`, userID, time.Now().Add(-s.Window)).Scan(&recent); err != nil {
+		return "", err
+	}
+	if s.MaxPerWindow > 0 && recent >= s.MaxPerWindow {
+		return "", ErrMagicLinkRateLimited
+	}
+
+	// link lama ditandai kadaluarsa (bukan dihapus) supaya tetap terhitung di batas
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE magic_link_tokens SET expires_at = NOW() WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()`,
+		userID,
+	); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO magic_link_tokens (user_id, token_hash, requested_ip, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, userID, utils.HashToken(token), ip, time.Now().Add(s.TTL)); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume memakai token (sekali pakai) dan mengembalikan user pemiliknya
+func (s *MagicLinkStore) Consume(ctx context.Context, token string) (int, error) {
+	var userID int
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE magic_link_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, utils.HashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrMagicLinkInvalid
+	}
+	return userID, err
+}
@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// jumlah recovery code yang dibuat setiap kali 2FA diaktifkan / dibuat ulang
+const recoveryCodeCount = 10
+
+const recoveryCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrMFANoPendingSetup = errors.New("no pending two-factor setup")
+	ErrMFAInvalidCode    = errors.New("invalid two-factor code")
+)
+
+// MFAStore menyimpan secret TOTP dan recovery code di tabel user_mfa / mfa_recovery_codes
+type MFAStore struct {
+	DB *sql.DB
+}
+
+func NewMFAStore(db *sql.DB) *MFAStore {
+	return &MFAStore{DB: db}
+}
+
+// IsEnabled true jika user sudah mengonfirmasi 2FA
+func (s *MFAStore) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	var enabled bool
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)
+	`, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// BeginSetup membuat secret baru yang belum aktif sampai dikonfirmasi lewat Confirm
+func (s *MFAStore) BeginSetup(ctx context.Context, userID int) (string, error) {
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if enabled {
+		return "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO user_mfa (user_id, secret, enabled_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+	`, userID, secret)
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Confirm mengaktifkan 2FA jika kode cocok dengan secret yang sedang di-setup,
+// lalu mengembalikan recovery code baru (plaintext, hanya ditampilkan sekali)
+func (s *MFAStore) Confirm(ctx context.Context, userID int, code string) ([]string, error) {
+	var secret string
+	var enabledAt sql.NullTime
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT secret, enabled_at FROM user_mfa WHERE user_id = $1`, userID,
+	).Scan(&secret, &enabledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANoPendingSetup
+		}
+		return nil, err
+	}
+	if enabledAt.Valid {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE user_mfa SET enabled_at = NOW(), last_used_step = $1 WHERE user_id = $2`, step, userID,
+	); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify mengecek kode TOTP atau recovery code milik user.
+// Kode TOTP yang sama tidak bisa dipakai dua kali; recovery code hanya sekali pakai.
+func (s *MFAStore) Verify(ctx context.Context, userID int, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		return s.useRecoveryCode(ctx, userID, recoveryCode)
+	}
+
+	var secret string
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT secret FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL`, userID,
+	).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+
+	step, ok := utils.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+
+	// last_used_step mencegah kode yang sama diputar ulang dalam window yang sama
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`, step, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+// Disable mematikan 2FA dan menghapus semua recovery code
+func (s *MFAStore) Disable(ctx context.Context, userID int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes mengganti semua recovery code lama dengan yang baru
+func (s *MFAStore) RegenerateRecoveryCodes(ctx context.Context, userID int) ([]string, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes jumlah recovery code yang belum dipakai
+func (s *MFAStore) RemainingRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID,
+	).Scan(&n)
+	return n, err
+}
+
+func (s *MFAStore) useRecoveryCode(ctx context.Context, userID int, code string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, utils.HashToken(normalizeRecoveryCode(code)),
+		); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// randomRecoveryCode format XXXXX-XXXXX
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = recoveryCodeChars[int(b[i])%len(recoveryCodeChars)]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
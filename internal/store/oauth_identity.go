@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrIdentityNotFound akun provider belum terhubung ke user mana pun / ke user ini
+	ErrIdentityNotFound = errors.New("oauth identity not found")
+	// ErrIdentityLinkedElsewhere akun provider sudah terhubung ke user lain
+	ErrIdentityLinkedElsewhere = errors.New("oauth identity is linked to another account")
+	// ErrIdentityProviderLinked user sudah punya akun lain dari provider yang sama
+	ErrIdentityProviderLinked = errors.New("an account from this provider is already linked")
+	// ErrLastLoginMethod unlink ditolak karena user tidak punya password maupun identity lain
+	ErrLastLoginMethod = errors.New("cannot unlink the only remaining login method")
+)
+
+// OAuthIdentity akun provider yang terhubung ke user
+type OAuthIdentity struct {
+	Provider    string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+}
+
+// OAuthIdentityStore menyimpan relasi (provider, subject) → user di tabel oauth_identities
+type OAuthIdentityStore struct {
+	DB *sql.DB
+}
+
+func NewOAuthIdentityStore(db *sql.DB) *OAuthIdentityStore {
+	return &OAuthIdentityStore{DB: db}
+}
+
+// FindUser user pemilik identity; ErrIdentityNotFound jika belum terhubung.
+// last_login_at ikut diperbarui karena Find hanya dipanggil saat login.
+func (s *OAuthIdentityStore) FindUser(ctx context.Context, provider, subject string) (int, error) {
+	var userID int
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE oauth_identities SET last_login_at = NOW()
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id
+	`, provider, subject).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrIdentityNotFound
+	}
+	return userID, err
+}
+
+// Link menghubungkan identity ke user
+func (s *OAuthIdentityStore) Link(ctx context.Context, userID int, provider, subject, email string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO oauth_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, userID, provider, subject, email)
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	// unique violation: identity sudah ada, atau user sudah punya akun lain dari provider ini
+	var owner int
+	err = s.DB.QueryRowContext(ctx,
+		`SELECT user_id FROM oauth_identities WHERE provider = $1 AND subject = $2`, provider, subject,
+	).Scan(&owner)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrIdentityProviderLinked
+	case err != nil:
+		return err
+	case owner != userID:
+		return ErrIdentityLinkedElsewhere
+	}
+	// identity yang sama di-link ulang ke user yang sama dianggap sukses
+	return nil
+}
+
+// List semua identity milik user
+func (s *OAuthIdentityStore) List(ctx context.Context, userID int) ([]OAuthIdentity, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT provider, subject, COALESCE(email, ''), created_at, last_login_at
+		FROM oauth_identities
+		WHERE user_id = $1
+		ORDER BY provider
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []OAuthIdentity{}
+	for rows.Next() {
+		var i OAuthIdentity
+		var lastLogin sql.NullTime
+		if err := rows.Scan(&i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &lastLogin); err != nil {
+			return nil, err
+		}
+		if lastLogin.Valid {
+			i.LastLoginAt = &lastLogin.Time
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// Unlink memutus identity dari user. Ditolak jika itu satu-satunya cara login
+// (user tanpa password dan tanpa identity lain).
+func (s *OAuthIdentityStore) Unlink(ctx context.Context, userID int, provider string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hasPassword bool
+	var identities int
+	err = tx.QueryRowContext(ctx, `
+		SELECT u.password IS NOT NULL,
+		       (SELECT COUNT(*) FROM oauth_identities WHERE user_id = u.id)
+		FROM users u WHERE u.id = $1
+		FOR UPDATE
+	`, userID).Scan(&hasPassword, &identities)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM oauth_identities WHERE user_id = $1 AND provider = $2`, userID, provider,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrIdentityNotFound
+	}
+	if !hasPassword && identities <= 1 {
+		return ErrLastLoginMethod
+	}
+	return tx.Commit()
+}
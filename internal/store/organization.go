@@ -0,0 +1,485 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/qwerius/gonuxt/internal/config"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// Role anggota di dalam satu organisasi. Berbeda dengan role global (tabel roles):
+// admin organisasi hanya mengelola organisasinya, superadmin (role global admin) semua organisasi.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var (
+	// ErrOrgNotFound organisasi tidak ditemukan
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrOrgSlugTaken slug sudah dipakai organisasi lain
+	ErrOrgSlugTaken = errors.New("organization slug already taken")
+	// ErrOrgNotMember user bukan anggota organisasi
+	ErrOrgNotMember = errors.New("not a member of this organization")
+	// ErrOrgLastOwner organisasi harus selalu punya minimal satu owner
+	ErrOrgLastOwner = errors.New("organization must keep at least one owner")
+	// ErrOrgInviteInvalid token undangan tidak dikenal, sudah dipakai, atau kadaluarsa
+	ErrOrgInviteInvalid = errors.New("invalid or expired invitation")
+	// ErrOrgInviteEmailMismatch undangan untuk email lain
+	ErrOrgInviteEmailMismatch = errors.New("invitation was sent to a different email")
+)
+
+// Organizations instance global, dipakai middleware.ResolveOrg (di-set di RegisterRoutes)
+var Organizations *OrganizationStore
+
+// OrgRoleValid true jika role dikenal
+func OrgRoleValid(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// OrgRoleCanManage owner & admin organisasi boleh mengelola anggota dan undangan
+func OrgRoleCanManage(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+// Organization satu tenant
+type Organization struct {
+	ID        int
+	Name      string
+	Slug      string
+	Role      string // role user yang meminta, kosong jika bukan anggota
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OrgMember anggota organisasi
+type OrgMember struct {
+	UserID   int
+	Email    string
+	Role     string
+	JoinedAt time.Time
+}
+
+// OrgInvite undangan yang belum diterima
+type OrgInvite struct {
+	ID        int
+	OrgID     int
+	Email     string
+	Role      string
+	InvitedBy int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// OrgScope organisasi aktif sebuah request, dipakai untuk membatasi query users/profiles/audit.
+// Superadmin tanpa organisasi aktif melihat semua data; user lain tanpa organisasi aktif
+// hanya melihat data user yang tidak tergabung di organisasi mana pun.
+type OrgScope struct {
+	OrgID      int    // 0 jika tidak ada organisasi aktif
+	Role       string // role di organisasi aktif, kosong jika superadmin bukan anggota
+	Superadmin bool
+}
+
+// UserCondition kondisi SQL untuk kolom user id (mis. "u.id"); argN nomor placeholder berikutnya
+func (s OrgScope) UserCondition(column string, argN int) (string, []interface{}) {
+	switch {
+	case s.OrgID > 0:
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM organization_members om WHERE om.org_id = $%d AND om.user_id = %s)", argN, column),
+			[]interface{}{s.OrgID}
+	case s.Superadmin:
+		return "TRUE", nil
+	default:
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM organization_members om WHERE om.user_id = %s)", column), nil
+	}
+}
+
+// OrgCondition kondisi SQL untuk kolom org id (mis. audit_logs.org_id)
+func (s OrgScope) OrgCondition(column string, argN int) (string, []interface{}) {
+	switch {
+	case s.OrgID > 0:
+		return fmt.Sprintf("%s = $%d", column, argN), []interface{}{s.OrgID}
+	case s.Superadmin:
+		return "TRUE", nil
+	default:
+		return column + " IS NULL", nil
+	}
+}
+
+// OrganizationStore menyimpan organisasi, anggota dan undangan
+type OrganizationStore struct {
+	DB *sql.DB
+	// InviteTTL masa berlaku undangan (ORG_INVITE_TTL, default 7 hari)
+	InviteTTL time.Duration
+}
+
+func NewOrganizationStore(db *sql.DB) *OrganizationStore {
+	return &OrganizationStore{
+		DB:        db,
+		InviteTTL: config.GetDuration("ORG_INVITE_TTL", 7*24*time.Hour),
+	}
+}
+
+// Create membuat organisasi dengan ownerID sebagai owner pertama
+func (s *OrganizationStore) Create(ctx context.Context, name, slug string, ownerID int) (*Organization, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	org := &Organization{Name: name, Slug: slug, Role: OrgRoleOwner}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO organizations (name, slug) VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, name, slug).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrOrgSlugTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)`,
+		org.ID, ownerID, OrgRoleOwner,
+	); err != nil {
+		return nil, err
+	}
+
+	return org, tx.Commit()
+}
+
+// Get organisasi beserta role userID di dalamnya (kosong jika bukan anggota)
+func (s *OrganizationStore) Get(ctx context.Context, orgID, userID int) (*Organization, error) {
+	var org Organization
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT o.id, o.name, o.slug, COALESCE(m.role, ''), o.created_at, o.updated_at
+		FROM organizations o
+		LEFT JOIN organization_members m ON m.org_id = o.id AND m.user_id = $2
+		WHERE o.id = $1
+	`, orgID, userID).Scan(&org.ID, &org.Name, &org.Slug, &org.Role, &org.CreatedAt, &org.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrgNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// List semua organisasi (superadmin), atau hanya organisasi tempat userID menjadi anggota
+func (s *OrganizationStore) List(ctx context.Context, userID int, all bool) ([]Organization, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT o.id, o.name, o.slug, COALESCE(m.role, ''), o.created_at, o.updated_at
+		FROM organizations o
+		LEFT JOIN organization_members m ON m.org_id = o.id AND m.user_id = $1
+		WHERE $2 OR m.user_id IS NOT NULL
+		ORDER BY o.name, o.id
+	`, userID, all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []Organization{}
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.Slug, &o.Role, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+	return orgs, rows.Err()
+}
+
+// Update mengganti nama dan/atau slug; nilai kosong tidak diubah
+func (s *OrganizationStore) Update(ctx context.Context, orgID int, name, slug string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE organizations
+		SET name = COALESCE(NULLIF($2, ''), name),
+		    slug = COALESCE(NULLIF($3, ''), slug),
+		    updated_at = NOW()
+		WHERE id = $1
+	`, orgID, name, slug)
+	if isUniqueViolation(err) {
+		return ErrOrgSlugTaken
+	}
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// Delete menghapus organisasi beserta keanggotaan dan undangannya
+func (s *OrganizationStore) Delete(ctx context.Context, orgID int) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, orgID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+// MemberRole role userID di organisasi; ErrOrgNotMember jika bukan anggota
+func (s *OrganizationStore) MemberRole(ctx context.Context, orgID, userID int) (string, error) {
+	var role string
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT role FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, userID,
+	).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrOrgNotMember
+	}
+	return role, err
+}
+
+// Memberships semua organisasi userID (org_id → role)
+func (s *OrganizationStore) Memberships(ctx context.Context, userID int) (map[int]string, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT org_id, role FROM organization_members WHERE user_id = $1`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var role string
+		if err := rows.Scan(&id, &role); err != nil {
+			return nil, err
+		}
+		orgs[id] = role
+	}
+	return orgs, rows.Err()
+}
+
+// Members daftar anggota organisasi
+func (s *OrganizationStore) Members(ctx context.Context, orgID int) ([]OrgMember, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT m.user_id, u.email, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = $1
+		ORDER BY m.created_at, m.user_id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []OrgMember{}
+	for rows.Next() {
+		var m OrgMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMemberRole mengganti role anggota; owner terakhir tidak bisa diturunkan
+func (s *OrganizationStore) SetMemberRole(ctx context.Context, orgID, userID int, role string) error {
+	return s.changeMember(ctx, orgID, userID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE organization_members SET role = $3 WHERE org_id = $1 AND user_id = $2`,
+			orgID, userID, role,
+		)
+		return err
+	}, role != OrgRoleOwner)
+}
+
+// RemoveMember mengeluarkan anggota; owner terakhir tidak bisa dikeluarkan
+func (s *OrganizationStore) RemoveMember(ctx context.Context, orgID, userID int) error {
+	return s.changeMember(ctx, orgID, userID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, userID,
+		)
+		return err
+	}, true)
+}
+
+// changeMember menjalankan perubahan anggota dengan baris anggota organisasi terkunci,
+// supaya dua perubahan bersamaan tidak menghapus semua owner
+func (s *OrganizationStore) changeMember(ctx context.Context, orgID, userID int, change func(*sql.Tx) error, dropsOwner bool) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT user_id, role FROM organization_members WHERE org_id = $1 FOR UPDATE`, orgID,
+	)
+	if err != nil {
+		return err
+	}
+	owners, current := 0, ""
+	for rows.Next() {
+		var id int
+		var role string
+		if err := rows.Scan(&id, &role); err != nil {
+			rows.Close()
+			return err
+		}
+		if role == OrgRoleOwner {
+			owners++
+		}
+		if id == userID {
+			current = role
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if current == "" {
+		return ErrOrgNotMember
+	}
+	if dropsOwner && current == OrgRoleOwner && owners <= 1 {
+		return ErrOrgLastOwner
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateInvite membuat undangan untuk email; token lengkap hanya dikembalikan sekali.
+// Undangan lama yang belum diterima untuk email yang sama diganti.
+func (s *OrganizationStore) CreateInvite(ctx context.Context, orgID int, email, role string, invitedBy int) (*OrgInvite, string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM organization_invites
+		WHERE org_id = $1 AND LOWER(email) = LOWER($2) AND accepted_at IS NULL
+	`, orgID, email); err != nil {
+		return nil, "", err
+	}
+
+	inv := &OrgInvite{OrgID: orgID, Email: email, Role: role, InvitedBy: invitedBy}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO organization_invites (org_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, expires_at, created_at
+	`, orgID, email, role, utils.HashToken(token), invitedBy, time.Now().Add(s.InviteTTL)).
+		Scan(&inv.ID, &inv.ExpiresAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return inv, token, nil
+}
+
+// Invites undangan yang belum diterima dan belum kadaluarsa
+func (s *OrganizationStore) Invites(ctx context.Context, orgID int) ([]OrgInvite, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, org_id, email, role, COALESCE(invited_by, 0), expires_at, created_at
+		FROM organization_invites
+		WHERE org_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []OrgInvite{}
+	for rows.Next() {
+		var inv OrgInvite
+		if err := rows.Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite membatalkan undangan; false jika tidak ditemukan
+func (s *OrganizationStore) RevokeInvite(ctx context.Context, orgID, inviteID int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM organization_invites
+		WHERE id = $1 AND org_id = $2 AND accepted_at IS NULL
+	`, inviteID, orgID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// AcceptInvite memakai token undangan (sekali pakai) dan menjadikan user anggota.
+// Email akun harus sama dengan email yang diundang. Anggota yang sudah ada tidak diturunkan.
+func (s *OrganizationStore) AcceptInvite(ctx context.Context, token string, userID int, email string) (*OrgInvite, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var inv OrgInvite
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, org_id, email, role, COALESCE(invited_by, 0), expires_at, created_at
+		FROM organization_invites
+		WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		FOR UPDATE
+	`, utils.HashToken(token)).Scan(&inv.ID, &inv.OrgID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &inv.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrgInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(inv.Email, email) {
+		return nil, ErrOrgInviteEmailMismatch
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE organization_invites SET accepted_at = NOW() WHERE id = $1`, inv.ID,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (org_id, user_id) DO NOTHING
+	`, inv.OrgID, userID, inv.Role); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// PasswordResetTTL masa berlaku link reset password
+const PasswordResetTTL = time.Hour
+
+// ErrResetTokenInvalid token tidak dikenal, sudah dipakai, atau kadaluarsa
+var ErrResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// PasswordResetStore menyimpan hash token reset password di tabel password_reset_tokens
+type PasswordResetStore struct {
+	DB *sql.DB
+}
+
+func NewPasswordResetStore(db *sql.DB) *PasswordResetStore {
+	return &PasswordResetStore{DB: db}
+}
+
+// Create membuat token reset baru; token lama yang belum dipakai langsung tidak berlaku
+func (s *PasswordResetStore) Create(ctx context.Context, userID int) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID,
+	); err != nil {
+		return "", err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, utils.HashToken(token), time.Now().Add(PasswordResetTTL)); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Lookup user pemilik token reset yang masih berlaku, tanpa memakai tokennya
+func (s *PasswordResetStore) Lookup(ctx context.Context, token string) (int, error) {
+	var userID int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`, utils.HashToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	return userID, err
+}
+
+// Reset memakai token (sekali pakai) dan mengganti password user dalam satu transaksi.
+// Lockout gagal login ikut dibuka karena pemilik email sudah terbukti.
+func (s *PasswordResetStore) Reset(ctx context.Context, token, hashedPassword string) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, utils.HashToken(token)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users
+		 SET password = $1, failed_login_count = 0, locked_until = NULL, updated_at = NOW()
+		 WHERE id = $2`, hashedPassword, userID,
+	); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Permission yang dicek middleware.RequirePermission. Permission baru ditambahkan lewat
+// migration, bukan lewat API, karena hanya berarti jika ada route yang memakainya.
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionRolesRead        = "roles:read"
+	PermissionRolesManage      = "roles:manage"
+	PermissionProfilesRead     = "profiles:read"
+	PermissionAuditRead        = "audit:read"
+	PermissionOrgsManage       = "orgs:manage"
+)
+
+// PermissionNames daftar semua permission yang di-seed migration
+var PermissionNames = []string{
+	PermissionUsersRead, PermissionUsersWrite, PermissionUsersImpersonate,
+	PermissionRolesRead, PermissionRolesManage,
+	PermissionProfilesRead,
+	PermissionAuditRead,
+	PermissionOrgsManage,
+}
+
+// AdminRole role bawaan yang selalu memiliki semua permission
+const AdminRole = "admin"
+
+// ErrPermissionUnknown permission tidak ada di tabel permissions
+var ErrPermissionUnknown = errors.New("unknown permission")
+
+// Permission satu baris tabel permissions
+type Permission struct {
+	ID          int
+	Name        string
+	Description string
+}
+
+// PermissionStore membaca & mengatur permission per role (tabel permissions, role_permissions)
+type PermissionStore struct {
+	DB *sql.DB
+}
+
+func NewPermissionStore(db *sql.DB) *PermissionStore {
+	return &PermissionStore{DB: db}
+}
+
+// List semua permission
+func (s *PermissionStore) List(ctx context.Context) ([]Permission, error) {
+	return s.query(ctx, `SELECT id, name, description FROM permissions ORDER BY name`)
+}
+
+// ForRole permission yang dimiliki role; ErrRoleNotFound jika role tidak ada
+func (s *PermissionStore) ForRole(ctx context.Context, roleID int) ([]Permission, error) {
+	if err := s.roleExists(ctx, roleID); err != nil {
+		return nil, err
+	}
+	return s.query(ctx, `
+		SELECT p.id, p.name, p.description
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		WHERE rp.role_id = $1
+		ORDER BY p.name
+	`, roleID)
+}
+
+// Grant memberi permission ke role; tidak error jika sudah dimiliki
+func (s *PermissionStore) Grant(ctx context.Context, roleID int, names []string) error {
+	if err := s.roleExists(ctx, roleID); err != nil {
+		return err
+	}
+
+	var known int
+	if err := s.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM permissions WHERE name = ANY($1)", pq.Array(names),
+	).Scan(&known); err != nil {
+		return err
+	}
+	if known != len(uniqueStrings(names)) {
+		return ErrPermissionUnknown
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, id FROM permissions WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, roleID, pq.Array(names))
+	return err
+}
+
+// Revoke mencabut permission dari role; false jika role tidak memiliki permission tersebut
+func (s *PermissionStore) Revoke(ctx context.Context, roleID int, name string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM role_permissions
+		WHERE role_id = $1 AND permission_id = (SELECT id FROM permissions WHERE name = $2)
+	`, roleID, name)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// PermissionGrant satu jalur yang memberi permission ke user
+type PermissionGrant struct {
+	// Role role yang diberi permission di role_permissions
+	Role string
+	// Path rantai dari role milik user sampai Role, mis. [moderator pelanggan]
+	Path []string
+}
+
+// Explain semua jalur role yang memberi permission ke user, termasuk lewat role yang
+// diwarisi; kosong jika user tidak memiliki permission tersebut
+func (s *PermissionStore) Explain(ctx context.Context, userID int, name string) ([]PermissionGrant, error) {
+	rows, err := s.DB.QueryContext(ctx, effectiveRolesCTE+`
+		SELECT er.name, er.path
+		FROM effective_roles er
+		JOIN role_permissions rp ON rp.role_id = er.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.name = $2
+		ORDER BY array_length(er.path, 1), er.path
+	`, userID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []PermissionGrant{}
+	for rows.Next() {
+		var g PermissionGrant
+		if err := rows.Scan(&g.Role, pq.Array(&g.Path)); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+func (s *PermissionStore) roleExists(ctx context.Context, roleID int) error {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1)", roleID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (s *PermissionStore) query(ctx context.Context, q string, args ...interface{}) ([]Permission, error) {
+	rows, err := s.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, rows.Err()
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
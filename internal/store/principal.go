@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Principals instance global, diisi saat routes didaftarkan
+var Principals *PrincipalStore
+
+// Principal user yang sedang request beserta role efektif (termasuk warisan) dan permission-nya
+type Principal struct {
+	UserID int
+	Roles  []EffectiveRole
+	// Permissions nama permission → jalur role yang memberikannya
+	Permissions map[string][]PermissionGrant
+}
+
+// HasRole true jika user memiliki role tersebut, langsung atau diwarisi
+func (p *Principal) HasRole(name string) bool {
+	for _, r := range p.Roles {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission true jika salah satu role user diberi permission tersebut
+func (p *Principal) HasPermission(name string) bool {
+	return len(p.Permissions[name]) > 0
+}
+
+// RoleNames nama semua role efektif
+func (p *Principal) RoleNames() []string {
+	names := make([]string, 0, len(p.Roles))
+	for _, r := range p.Roles {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// PermissionNames nama semua permission yang dimiliki
+func (p *Principal) PermissionNames() []string {
+	names := make([]string, 0, len(p.Permissions))
+	for _, name := range PermissionNames {
+		if p.HasPermission(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type principalItem struct {
+	principal *Principal
+	loadedAt  time.Time
+	// roleExpiry assignment role terdekat yang akan kadaluarsa; cache tidak dipakai lewat dari itu
+	roleExpiry sql.NullTime
+}
+
+func (i principalItem) fresh(now time.Time, ttl time.Duration) bool {
+	if i.roleExpiry.Valid && !now.Before(i.roleExpiry.Time) {
+		return false
+	}
+	return now.Sub(i.loadedAt) < ttl
+}
+
+// PrincipalStore memuat Principal dari DB dan meng-cache-nya in-process selama TTL.
+// Perubahan role user / permission role dari instance ini langsung menghapus cache
+// lewat Invalidate / InvalidateAll; instance lain melihatnya setelah TTL habis.
+type PrincipalStore struct {
+	DB    *sql.DB
+	Roles *RoleStore
+	TTL   time.Duration
+
+	mu    sync.Mutex
+	items map[int]principalItem
+	// gen naik setiap invalidasi, supaya hasil load yang dimulai sebelum invalidasi tidak di-cache
+	gen      uint64
+	prunedAt time.Time
+}
+
+func NewPrincipalStore(db *sql.DB, ttl time.Duration) *PrincipalStore {
+	return &PrincipalStore{
+		DB:    db,
+		Roles: NewRoleStore(db),
+		TTL:   ttl,
+		items: make(map[int]principalItem),
+	}
+}
+
+// Load principal user dari cache, atau dari DB jika belum ada / sudah lewat TTL
+func (s *PrincipalStore) Load(ctx context.Context, userID int) (*Principal, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	item, ok := s.items[userID]
+	gen := s.gen
+	s.mu.Unlock()
+	if ok && item.fresh(now, s.TTL) {
+		return item.principal, nil
+	}
+
+	p, roleExpiry, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.gen == gen {
+		s.items[userID] = principalItem{principal: p, loadedAt: now, roleExpiry: roleExpiry}
+		s.pruneLocked(now)
+	}
+	s.mu.Unlock()
+	return p, nil
+}
+
+// Invalidate menghapus cache satu user, dipanggil setelah role user berubah
+func (s *PrincipalStore) Invalidate(userID int) {
+	s.mu.Lock()
+	delete(s.items, userID)
+	s.gen++
+	s.mu.Unlock()
+}
+
+// InvalidateAll menghapus seluruh cache, dipanggil setelah role, parent role
+// atau permission role berubah (bisa mengenai banyak user)
+func (s *PrincipalStore) InvalidateAll() {
+	s.mu.Lock()
+	s.items = make(map[int]principalItem)
+	s.gen++
+	s.mu.Unlock()
+}
+
+func (s *PrincipalStore) load(ctx context.Context, userID int) (*Principal, sql.NullTime, error) {
+	var roleExpiry sql.NullTime
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT MIN(expires_at) FROM user_roles WHERE user_id = $1 AND expires_at > NOW()
+	`, userID).Scan(&roleExpiry); err != nil {
+		return nil, roleExpiry, err
+	}
+
+	roles, err := s.Roles.EffectiveRoles(ctx, userID)
+	if err != nil {
+		return nil, roleExpiry, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, effectiveRolesCTE+`
+		SELECT p.name, er.name, er.path
+		FROM effective_roles er
+		JOIN role_permissions rp ON rp.role_id = er.id
+		JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY p.name, array_length(er.path, 1), er.path
+	`, userID)
+	if err != nil {
+		return nil, roleExpiry, err
+	}
+	defer rows.Close()
+
+	p := &Principal{UserID: userID, Roles: roles, Permissions: make(map[string][]PermissionGrant)}
+	for rows.Next() {
+		var name string
+		var g PermissionGrant
+		if err := rows.Scan(&name, &g.Role, pq.Array(&g.Path)); err != nil {
+			return nil, roleExpiry, err
+		}
+		p.Permissions[name] = append(p.Permissions[name], g)
+	}
+	return p, roleExpiry, rows.Err()
+}
+
+// pruneLocked membuang entri kadaluarsa paling sering sekali per TTL; dipanggil dengan mu terkunci
+func (s *PrincipalStore) pruneLocked(now time.Time) {
+	if now.Sub(s.prunedAt) < s.TTL {
+		return
+	}
+	s.prunedAt = now
+	for id, item := range s.items {
+		if !item.fresh(now, s.TTL) {
+			delete(s.items, id)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qwerius/gonuxt/internal/utils"
+)
+
+// RefreshTokenTTL masa berlaku satu refresh token
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrRefreshTokenInvalid token tidak dikenal, sudah dicabut, atau kadaluarsa
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused token yang sudah dirotasi dipakai lagi → seluruh family dicabut
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// RefreshTokenMeta info perangkat yang disimpan bersama token
+type RefreshTokenMeta struct {
+	Device string
+	IP     string
+}
+
+// RefreshTokenStore menyimpan hash refresh token di tabel refresh_tokens.
+// Setiap login membuat family baru; setiap refresh merotasi token di family yang sama.
+type RefreshTokenStore struct {
+	DB *sql.DB
+}
+
+func NewRefreshTokenStore(db *sql.DB) *RefreshTokenStore {
+	return &RefreshTokenStore{DB: db}
+}
+
+// Issue membuat refresh token baru dengan family baru (dipakai saat login).
+// authTime waktu user membuktikan identitasnya, disimpan untuk claim auth_time.
+func (s *RefreshTokenStore) Issue(ctx context.Context, userID int, authTime time.Time, meta RefreshTokenMeta) (token, familyID string, err error) {
+	familyID = uuid.New().String()
+	token, err = s.insert(ctx, s.DB, userID, familyID, authTime, meta)
+	if err != nil {
+		return "", "", err
+	}
+	return token, familyID, nil
+}
+
+// RotatedToken hasil Rotate
+type RotatedToken struct {
+	UserID   int
+	FamilyID string
+	Token    string
+	AuthTime time.Time // auth_time sesi, tidak berubah karena refresh
+}
+
+// Rotate menukar refresh token lama dengan token baru di family yang sama.
+// Jika token yang sudah pernah dirotasi dipakai lagi, seluruh family dicabut
+// dan ErrRefreshTokenReused dikembalikan bersama UserID dan FamilyID-nya.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token string, meta RefreshTokenMeta) (RotatedToken, error) {
+	var rt RotatedToken
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rt, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id                   int
+		expiresAt            time.Time
+		rotatedAt, revokedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at, auth_time
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, utils.HashToken(token)).Scan(&id, &rt.UserID, &rt.FamilyID, &expiresAt, &rotatedAt, &revokedAt, &rt.AuthTime)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return RotatedToken{}, ErrRefreshTokenInvalid
+		}
+		return RotatedToken{}, err
+	}
+
+	if rotatedAt.Valid {
+		// token lama diputar ulang → anggap dicuri, cabut semua token di family
+		if err := revokeFamily(ctx, tx, rt.FamilyID); err != nil {
+			return RotatedToken{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return RotatedToken{}, err
+		}
+		return RotatedToken{UserID: rt.UserID, FamilyID: rt.FamilyID}, ErrRefreshTokenReused
+	}
+
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return RotatedToken{}, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET rotated_at = NOW(), revoked_at = NOW() WHERE id = $1`, id,
+	); err != nil {
+		return RotatedToken{}, err
+	}
+
+	rt.Token, err = s.insert(ctx, tx, rt.UserID, rt.FamilyID, rt.AuthTime, meta)
+	if err != nil {
+		return RotatedToken{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RotatedToken{}, err
+	}
+	return rt, nil
+}
+
+// Reauthenticated memperbarui auth_time sesi setelah user memasukkan ulang
+// password / faktor kedua (step-up), sehingga token hasil refresh berikutnya ikut baru
+func (s *RefreshTokenStore) Reauthenticated(ctx context.Context, familyID string, authTime time.Time) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE refresh_tokens SET auth_time = $2
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID, authTime)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRefreshTokenInvalid
+	}
+	return nil
+}
+
+// Revoke mencabut family dari refresh token tertentu (dipakai saat logout)
+func (s *RefreshTokenStore) Revoke(ctx context.Context, token string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL
+		  AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)
+	`, utils.HashToken(token))
+	return err
+}
+
+// RevokeFamily mencabut semua token aktif dalam satu family
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return revokeFamily(ctx, s.DB, familyID)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func revokeFamily(ctx context.Context, db execer, familyID string) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`,
+		familyID,
+	)
+	return err
+}
+
+func (s *RefreshTokenStore) insert(ctx context.Context, db execer, userID int, familyID string, authTime time.Time, meta RefreshTokenMeta) (string, error) {
+	token, err := utils.CreateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, device, ip, issued_at, expires_at, auth_time)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6, $7)
+	`, userID, utils.HashToken(token), familyID, meta.Device, meta.IP, time.Now().Add(RefreshTokenTTL), authTime)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRotateReuseRevokesFamily(t *testing.T) {
+	conn := openTestDB(t)
+	s := NewRefreshTokenStore(conn)
+	ctx := context.Background()
+	userID := createTestUser(t, conn, "rotate@example.com")
+	meta := RefreshTokenMeta{Device: "test", IP: "127.0.0.1"}
+
+	first, familyID, err := s.Issue(ctx, userID, time.Now(), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.Rotate(ctx, first, meta)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.FamilyID != familyID || rotated.UserID != userID {
+		t.Fatalf("rotate: got family=%s user=%d, want family=%s user=%d",
+			rotated.FamilyID, rotated.UserID, familyID, userID)
+	}
+	if rotated.Token == first {
+		t.Fatal("rotate: returned the same token")
+	}
+
+	// token lama dipakai lagi → reuse terdeteksi
+	replay, err := s.Rotate(ctx, first, meta)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replay: got %v, want ErrRefreshTokenReused", err)
+	}
+	if replay.FamilyID != familyID || replay.UserID != userID {
+		t.Fatalf("replay: got family=%s user=%d", replay.FamilyID, replay.UserID)
+	}
+
+	// token terbaru di family yang sama ikut dicabut
+	if _, err := s.Rotate(ctx, rotated.Token, meta); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("rotate after reuse: got %v, want ErrRefreshTokenInvalid", err)
+	}
+
+	var active int
+	if err := conn.QueryRow(
+		"SELECT COUNT(*) FROM refresh_tokens WHERE family_id = $1 AND revoked_at IS NULL", familyID,
+	).Scan(&active); err != nil {
+		t.Fatal(err)
+	}
+	if active != 0 {
+		t.Fatalf("family still has %d active tokens", active)
+	}
+}
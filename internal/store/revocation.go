@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// revocationRecheck berapa lama hasil "tidak dicabut" dan watermark user di-cache
+// sebelum dicek ulang ke DB (supaya pencabutan dari instance lain ikut terlihat)
+const revocationRecheck = 30 * time.Second
+
+// Revocations instance global, diisi saat routes didaftarkan
+var Revocations *RevocationStore
+
+type watermarkItem struct {
+	validAfter time.Time // zero = tidak ada watermark
+	exists     bool      // false jika user sudah dihapus
+	checkedAt  time.Time
+}
+
+// RevocationStore denylist access token di tabel revoked_tokens + cache in-process.
+// Key bisa berupa jti (satu token) atau sid (semua token dari satu sesi).
+type RevocationStore struct {
+	DB *sql.DB
+
+	mu         sync.Mutex
+	revoked    map[string]time.Time // key → sampai kapan dicabut (exp token)
+	notRevoked map[string]time.Time // key → kapan terakhir dicek ke DB
+	watermarks map[int]watermarkItem
+}
+
+func NewRevocationStore(db *sql.DB) *RevocationStore {
+	return &RevocationStore{
+		DB:         db,
+		revoked:    make(map[string]time.Time),
+		notRevoked: make(map[string]time.Time),
+		watermarks: make(map[int]watermarkItem),
+	}
+}
+
+// Revoke memasukkan jti/sid ke denylist sampai expiresAt (lifetime token)
+func (s *RevocationStore) Revoke(ctx context.Context, tokenID string, userID int, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (token_id, user_id, expires_at)
+		VALUES ($1, NULLIF($2, 0), $3)
+		ON CONFLICT (token_id) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)
+	`, tokenID, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	// bersihkan entri yang tokennya sudah kadaluarsa
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revoked[tokenID] = expiresAt
+	delete(s.notRevoked, tokenID)
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked mengecek apakah jti/sid ada di denylist
+func (s *RevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if tokenID == "" {
+		return false, nil
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	if until, ok := s.revoked[tokenID]; ok {
+		if now.Before(until) {
+			s.mu.Unlock()
+			return true, nil
+		}
+		delete(s.revoked, tokenID)
+	}
+	if checkedAt, ok := s.notRevoked[tokenID]; ok && now.Sub(checkedAt) < revocationRecheck {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.mu.Unlock()
+
+	var expiresAt time.Time
+	err := s.DB.QueryRowContext(ctx,
+		`SELECT expires_at FROM revoked_tokens WHERE token_id = $1 AND expires_at > NOW()`, tokenID,
+	).Scan(&expiresAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictExpired(now)
+	if err == nil {
+		s.revoked[tokenID] = expiresAt
+		return true, nil
+	}
+	s.notRevoked[tokenID] = now
+	return false, nil
+}
+
+// BumpWatermark membuat semua token user yang diterbitkan sebelum sekarang tidak berlaku
+// (dipakai setelah ganti password atau force-logout oleh admin)
+func (s *RevocationStore) BumpWatermark(ctx context.Context, userID int) error {
+	now := time.Now()
+	if _, err := s.DB.ExecContext(ctx,
+		`UPDATE users SET tokens_valid_after = $1 WHERE id = $2`, now, userID,
+	); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.watermarks[userID] = watermarkItem{validAfter: now, exists: true, checkedAt: now}
+	s.mu.Unlock()
+	return nil
+}
+
+// Forget menandai user sudah dihapus sehingga semua tokennya langsung ditolak
+func (s *RevocationStore) Forget(userID int) {
+	s.mu.Lock()
+	s.watermarks[userID] = watermarkItem{exists: false, checkedAt: time.Now()}
+	s.mu.Unlock()
+}
+
+// IssuedBeforeWatermark true jika token (iat) diterbitkan sebelum watermark user,
+// atau user sudah tidak ada.
+func (s *RevocationStore) IssuedBeforeWatermark(ctx context.Context, userID int, issuedAt time.Time) (bool, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	item, ok := s.watermarks[userID]
+	s.mu.Unlock()
+
+	if !ok || now.Sub(item.checkedAt) >= revocationRecheck {
+		var validAfter sql.NullTime
+		err := s.DB.QueryRowContext(ctx,
+			`SELECT tokens_valid_after FROM users WHERE id = $1`, userID,
+		).Scan(&validAfter)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return false, err
+		}
+
+		item = watermarkItem{exists: err == nil, validAfter: validAfter.Time, checkedAt: now}
+		s.mu.Lock()
+		s.watermarks[userID] = item
+		s.mu.Unlock()
+	}
+
+	if !item.exists {
+		return true, nil
+	}
+	// iat hanya presisi detik: token yang terbit di detik yang sama dengan watermark ikut dicabut
+	return !issuedAt.After(item.validAfter.Truncate(time.Second)), nil
+}
+
+// evictExpired membuang cache yang sudah tidak relevan; dipanggil dengan mu terkunci
+func (s *RevocationStore) evictExpired(now time.Time) {
+	for k, until := range s.revoked {
+		if now.After(until) {
+			delete(s.revoked, k)
+		}
+	}
+	for k, checkedAt := range s.notRevoked {
+		if now.Sub(checkedAt) >= revocationRecheck {
+			delete(s.notRevoked, k)
+		}
+	}
+}
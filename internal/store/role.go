@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRoleNotFound role tidak ditemukan
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleParentNotFound parent role tidak ditemukan
+	ErrRoleParentNotFound = errors.New("parent role not found")
+	// ErrRoleCycle parent baru akan membuat role mewarisi dirinya sendiri
+	ErrRoleCycle = errors.New("role hierarchy cycle")
+)
+
+// effectiveRolesCTE semua role milik user $1 beserta role yang diwarisi lewat parent_id.
+// Assignment yang sudah lewat expires_at diabaikan walaupun belum dihapus sweeper.
+// path berisi rantai dari role yang di-assign ke user sampai role tersebut; role yang
+// sudah ada di path tidak dikunjungi lagi sehingga data lama yang bersiklus tetap aman.
+const effectiveRolesCTE = `
+WITH RECURSIVE effective_roles AS (
+	SELECT r.id, r.name, r.parent_id, ARRAY[r.name] AS path
+	FROM user_roles ur
+	JOIN roles r ON r.id = ur.role_id
+	WHERE ur.user_id = $1 AND (ur.expires_at IS NULL OR ur.expires_at > NOW())
+	UNION ALL
+	SELECT p.id, p.name, p.parent_id, er.path || p.name
+	FROM effective_roles er
+	JOIN roles p ON p.id = er.parent_id
+	WHERE NOT p.name = ANY(er.path)
+)`
+
+// EffectiveRole role yang dimiliki user, langsung atau diwarisi
+type EffectiveRole struct {
+	ID   int
+	Name string
+	// Path rantai pewarisan, mis. [moderator pelanggan]; satu elemen jika di-assign langsung
+	Path []string
+}
+
+// Inherited true jika role didapat lewat parent, bukan di-assign langsung
+func (r EffectiveRole) Inherited() bool {
+	return len(r.Path) > 1
+}
+
+// RoleStore membaca hierarki role (roles.parent_id)
+type RoleStore struct {
+	DB *sql.DB
+}
+
+func NewRoleStore(db *sql.DB) *RoleStore {
+	return &RoleStore{DB: db}
+}
+
+// EffectiveRoles semua role user termasuk yang diwarisi; jika satu role bisa dicapai
+// lewat beberapa jalur, yang dipakai jalur terpendek
+func (s *RoleStore) EffectiveRoles(ctx context.Context, userID int) ([]EffectiveRole, error) {
+	rows, err := s.DB.QueryContext(ctx, effectiveRolesCTE+`
+		SELECT id, name, path FROM (
+			SELECT DISTINCT ON (id) id, name, path
+			FROM effective_roles
+			ORDER BY id, array_length(path, 1)
+		) r
+		ORDER BY array_length(path, 1), id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []EffectiveRole{}
+	for rows.Next() {
+		var r EffectiveRole
+		if err := rows.Scan(&r.ID, &r.Name, pq.Array(&r.Path)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// HasRole true jika user memiliki role tersebut, langsung atau diwarisi
+func (s *RoleStore) HasRole(ctx context.Context, userID int, name string) (bool, error) {
+	var has bool
+	err := s.DB.QueryRowContext(ctx, effectiveRolesCTE+`
+		SELECT EXISTS(SELECT 1 FROM effective_roles WHERE name = $2)
+	`, userID, name).Scan(&has)
+	return has, err
+}
+
+// SetParent mengganti parent role (nil = tidak mewarisi). Tabel roles dikunci selama
+// pengecekan supaya dua perubahan bersamaan tidak bisa membentuk siklus.
+func (s *RoleStore) SetParent(ctx context.Context, roleID int, parentID *int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE roles IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1)", roleID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	if parentID != nil {
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM roles WHERE id = $1)", *parentID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRoleParentNotFound
+		}
+
+		// siklus jika role ini ada di antara parent baru dan leluhurnya
+		var cycle bool
+		err := tx.QueryRowContext(ctx, `
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM roles WHERE id = $1
+				UNION
+				SELECT r.id, r.parent_id FROM roles r JOIN ancestors a ON r.id = a.parent_id
+			)
+			SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)
+		`, *parentID, roleID).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrRoleCycle
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE roles SET parent_id = $1 WHERE id = $2", parentID, roleID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
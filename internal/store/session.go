@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Session satu login aktif = satu family refresh token yang belum dicabut
+type Session struct {
+	ID         string
+	UserID     int
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
+// ListSessions mengembalikan semua sesi aktif milik user, terbaru dulu.
+// LastSeenAt diambil dari rotasi terakhir (setiap /auth/refresh).
+func (s *RefreshTokenStore) ListSessions(ctx context.Context, userID int) ([]Session, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT rt.family_id, rt.user_id, COALESCE(rt.device, ''), COALESCE(rt.ip, ''),
+		       (SELECT MIN(f.issued_at) FROM refresh_tokens f WHERE f.family_id = rt.family_id),
+		       rt.issued_at, rt.expires_at
+		FROM refresh_tokens rt
+		WHERE rt.user_id = $1
+		  AND rt.revoked_at IS NULL
+		  AND rt.expires_at > NOW()
+		ORDER BY rt.issued_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var sess Session
+		if err := rows.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP,
+			&sess.CreatedAt, &sess.LastSeenAt, &sess.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession mencabut satu sesi milik user. Mengembalikan false jika
+// sesi tidak ditemukan atau sudah tidak aktif.
+func (s *RefreshTokenStore) RevokeSession(ctx context.Context, userID int, sessionID string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND family_id::text = $2 AND revoked_at IS NULL
+	`, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// RevokeOtherSessions mencabut semua sesi user kecuali exceptSessionID
+// (kosongkan untuk mencabut semuanya). Mengembalikan ID sesi yang dicabut.
+func (s *RefreshTokenStore) RevokeOtherSessions(ctx context.Context, userID int, exceptSessionID string) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() AND family_id::text <> $2
+		RETURNING family_id::text
+	`, userID, exceptSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/qwerius/gonuxt/internal/db/migrations"
+)
+
+// openTestDB membuka database dari TEST_DATABASE_URL di schema baru yang sudah dimigrasi.
+// Test dilewati jika TEST_DATABASE_URL kosong; schema dihapus setelah test selesai.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Logf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	conn, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	for _, m := range migrations.Migrations {
+		if _, err := conn.Exec(m.Up); err != nil {
+			t.Fatalf("migration %d %s: %v", m.Version, m.Name, err)
+		}
+	}
+	return conn
+}
+
+// createTestUser menyisipkan user minimal dan mengembalikan id-nya
+func createTestUser(t *testing.T, conn *sql.DB, email string) int {
+	t.Helper()
+
+	var id int
+	if err := conn.QueryRow(
+		"INSERT INTO users (email, password) VALUES ($1, '') RETURNING id", email,
+	).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// RoleGrant assignment role ke user; ExpiresAt nil berarti permanen
+type RoleGrant struct {
+	UserID    int
+	Email     string
+	RoleID    int
+	Role      string
+	GrantedBy *int
+	GrantedAt time.Time
+	ExpiresAt *time.Time
+}
+
+// UserRoleStore assignment role (user_roles) yang bisa dibatasi waktu
+type UserRoleStore struct {
+	DB *sql.DB
+}
+
+func NewUserRoleStore(db *sql.DB) *UserRoleStore {
+	return &UserRoleStore{DB: db}
+}
+
+// Assign memberi role ke user. Jika user sudah punya role tersebut, expires_at dan granted_by
+// diganti, sehingga assignment sementara bisa diperpanjang atau dijadikan permanen (expiresAt nil).
+func (s *UserRoleStore) Assign(ctx context.Context, userID, roleID, grantedBy int, expiresAt *time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id, granted_by, expires_at)
+		VALUES ($1, $2, NULLIF($3, 0), $4)
+		ON CONFLICT (user_id, role_id) DO UPDATE
+		SET granted_by = EXCLUDED.granted_by, expires_at = EXCLUDED.expires_at
+	`, userID, roleID, grantedBy, expiresAt)
+	return err
+}
+
+// Expiring assignment yang akan kadaluarsa dalam rentang within, urut dari yang paling dekat
+func (s *UserRoleStore) Expiring(ctx context.Context, within time.Duration, limit, offset int) ([]RoleGrant, int, error) {
+	until := time.Now().Add(within)
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM user_roles
+		WHERE expires_at > NOW() AND expires_at <= $1
+	`, until).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT ur.user_id, u.email, ur.role_id, r.name, ur.granted_by, ur.created_at, ur.expires_at
+		FROM user_roles ur
+		JOIN users u ON u.id = ur.user_id
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.expires_at > NOW() AND ur.expires_at <= $1
+		ORDER BY ur.expires_at, ur.user_id, ur.role_id
+		LIMIT $2 OFFSET $3
+	`, until, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	grants := []RoleGrant{}
+	for rows.Next() {
+		var g RoleGrant
+		var grantedBy sql.NullInt64
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&g.UserID, &g.Email, &g.RoleID, &g.Role, &grantedBy, &g.GrantedAt, &expiresAt); err != nil {
+			return nil, 0, err
+		}
+		if grantedBy.Valid {
+			id := int(grantedBy.Int64)
+			g.GrantedBy = &id
+		}
+		if expiresAt.Valid {
+			g.ExpiresAt = &expiresAt.Time
+		}
+		grants = append(grants, g)
+	}
+	return grants, total, rows.Err()
+}
+
+// SweepExpired menghapus assignment yang sudah lewat expires_at dan mencatat setiap
+// penghapusan di audit_logs (method EXPIRE, tanpa IP) dalam satu statement
+func (s *UserRoleStore) SweepExpired(ctx context.Context) ([]RoleGrant, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH expired AS (
+			DELETE FROM user_roles
+			WHERE expires_at <= NOW()
+			RETURNING user_id, role_id, granted_by, created_at, expires_at
+		), logged AS (
+			INSERT INTO audit_logs (user_id, method, url, status)
+			SELECT user_id, 'EXPIRE', '/api/v1/users/' || user_id || '/roles/' || role_id, 200
+			FROM expired
+		)
+		SELECT e.user_id, e.role_id, COALESCE(r.name, ''), e.granted_by, e.created_at, e.expires_at
+		FROM expired e
+		LEFT JOIN roles r ON r.id = e.role_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var grants []RoleGrant
+	for rows.Next() {
+		var g RoleGrant
+		var grantedBy sql.NullInt64
+		var expiresAt time.Time
+		if err := rows.Scan(&g.UserID, &g.RoleID, &g.Role, &grantedBy, &g.GrantedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		if grantedBy.Valid {
+			id := int(grantedBy.Int64)
+			g.GrantedBy = &id
+		}
+		g.ExpiresAt = &expiresAt
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// RunExpirySweeper menjalankan SweepExpired setiap interval sampai ctx selesai.
+// Principal user yang role-nya dihapus dibuang dari cache.
+func (s *UserRoleStore) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sweepCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		grants, err := s.SweepExpired(sweepCtx)
+		cancel()
+		if err != nil {
+			log.Printf("RunExpirySweeper: %v", err)
+			continue
+		}
+
+		for _, g := range grants {
+			log.Printf("[AUDIT] role expired user=%d role=%s expires_at=%s",
+				g.UserID, g.Role, g.ExpiresAt.Format(time.RFC3339),
+			)
+			if Principals != nil {
+				Principals.Invalidate(g.UserID)
+			}
+		}
+	}
+}
@@ -3,76 +3,260 @@ package utils
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET")) // ambil dari env
+// AccessTokenTTL masa berlaku access token
+const AccessTokenTTL = time.Hour
+
+// nilai header "typ" untuk membedakan access token (RFC 9068) dari token lain
+const (
+	accessTokenType = "at+jwt"
+	actionTokenType = "JWT"
+)
+
+// AccessClaims isi access token
+type AccessClaims struct {
+	UserID    int         `json:"user_id"`
+	SessionID string      `json:"sid,omitempty"` // family refresh token tempat token ini diterbitkan
+	Actor     *ActorClaim `json:"act,omitempty"` // terisi saat admin meng-impersonate user
+	// AuthTime kapan user terakhir memasukkan password / faktor kedua (OIDC auth_time);
+	// tetap sama saat token di-refresh
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ActorClaim claim "act" (RFC 8693): user yang sebenarnya memakai token impersonasi
+type ActorClaim struct {
+	Subject string `json:"sub"`
+	UserID  int    `json:"user_id"`
+}
+
+// Impersonating true jika token ini token impersonasi
+func (c *AccessClaims) Impersonating() bool {
+	return c.Actor != nil
+}
+
+// AuthenticatedWithin true jika auth_time tidak lebih lama dari maxAge
+func (c *AccessClaims) AuthenticatedWithin(maxAge time.Duration) bool {
+	return c.AuthTime != nil && time.Since(c.AuthTime.Time) <= maxAge
+}
+
+// tokenIssuer nilai claim iss (JWT_ISSUER, mis. https://api.example.com)
+func tokenIssuer() string {
+	return os.Getenv("JWT_ISSUER")
+}
+
+// tokenAudience nilai claim aud untuk access token (JWT_AUDIENCE, dipisah koma)
+func tokenAudience() []string {
+	var aud []string
+	for _, v := range strings.Split(os.Getenv("JWT_AUDIENCE"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			aud = append(aud, v)
+		}
+	}
+	return aud
+}
+
+// CreateAccessToken membuat access token untuk user; sessionID boleh kosong
+// untuk token yang tidak terikat sesi login. authTime diisi ke claim auth_time.
+func CreateAccessToken(userID int, sessionID string, authTime time.Time) (string, error) {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	return ks.sign(newAccessClaims(userID, sessionID, authTime, AccessTokenTTL), accessTokenType)
+}
+
+// CreateImpersonationToken access token untuk userID yang dipakai actorID (admin).
+// Token terikat ke sesi admin (sessionID, auth_time admin) dan tidak punya refresh token;
+// ttl sebaiknya pendek.
+func CreateImpersonationToken(userID, actorID int, sessionID string, authTime time.Time, ttl time.Duration) (string, *AccessClaims, error) {
+	if actorID == 0 || actorID == userID {
+		return "", nil, errors.New("invalid impersonation actor")
+	}
 
-func CreateAccessToken(userID int) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 1).Unix(),
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return "", nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	claims := newAccessClaims(userID, sessionID, authTime, ttl)
+	claims.Actor = &ActorClaim{Subject: strconv.Itoa(actorID), UserID: actorID}
+
+	signed, err := ks.sign(claims, accessTokenType)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-func CreateRefreshToken(userID int) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(), // 7 hari
+func newAccessClaims(userID int, sessionID string, authTime time.Time, ttl time.Duration) *AccessClaims {
+	now := time.Now()
+	claims := &AccessClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(), // jti, dipakai untuk denylist
+			Issuer:    tokenIssuer(),
+			Subject:   strconv.Itoa(userID),
+			Audience:  tokenAudience(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return claims
+}
+
+// parserOptions opsi validasi standar: algoritma keyset, exp & iat wajib, iss bila dikonfigurasi
+func parserOptions(ks *KeySet, audience []string) []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(ks.algorithms()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if iss := tokenIssuer(); iss != "" {
+		opts = append(opts, jwt.WithIssuer(iss))
+	}
+	if len(audience) > 0 {
+		opts = append(opts, jwt.WithAudience(audience...))
+	}
+	return opts
+}
+
+// ParseAccessToken memvalidasi access token JWT dan mengembalikan seluruh claims
+func ParseAccessToken(tokenStr string) (*AccessClaims, error) {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, parserOptions(ks, tokenAudience())...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid access token")
+	}
+	if typ, _ := token.Header["typ"].(string); typ != accessTokenType {
+		return nil, errors.New("not an access token")
+	}
+	if claims.UserID == 0 || claims.Subject != strconv.Itoa(claims.UserID) {
+		return nil, errors.New("user_id not found in token")
+	}
+	if a := claims.Actor; a != nil && (a.UserID == 0 || a.UserID == claims.UserID || a.Subject != strconv.Itoa(a.UserID)) {
+		return nil, errors.New("invalid act claim")
+	}
+
+	return claims, nil
 }
 
 // ValidateAccessToken memvalidasi access token JWT dan mengembalikan user_id
 func ValidateAccessToken(tokenStr string) (int, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		// Pastikan algoritma HS256
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
-
+	claims, err := ParseAccessToken(tokenStr)
 	if err != nil {
 		return 0, err
 	}
+	return claims.UserID, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if userID, ok := claims["user_id"].(float64); ok {
-			return int(userID), nil
-		}
-		return 0, errors.New("user_id not found in token")
+// ActionClaims token berumur pendek untuk satu tujuan tertentu (mis. tantangan MFA).
+// Tidak punya claim user_id maupun aud sehingga tidak bisa dipakai sebagai access token.
+type ActionClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// CreateActionToken membuat token bertanda tangan untuk purpose tertentu dengan masa berlaku ttl
+func CreateActionToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := ActionClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    tokenIssuer(),
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
 	}
 
-	return 0, errors.New("invalid access token")
+	return ks.sign(claims, actionTokenType)
 }
 
+// ParseActionToken memvalidasi token dan memastikan purpose sesuai, lalu mengembalikan user_id
+func ParseActionToken(tokenStr, purpose string) (int, *ActionClaims, error) {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return 0, nil, err
+	}
 
-// ValidateRefreshToken memvalidasi token refresh dan mengembalikan user_id
-func ValidateRefreshToken(tokenStr string) (int, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		// pastikan algoritma HS256
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
+	// tanpa aud: token aksi tidak ditujukan untuk layanan lain
+	claims := &ActionClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc, parserOptions(ks, nil)...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ != actionTokenType || len(claims.Audience) > 0 {
+		return 0, nil, errors.New("invalid token")
+	}
+	if !token.Valid || claims.Purpose != purpose {
+		return 0, nil, errors.New("invalid token")
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID == 0 {
+		return 0, nil, errors.New("invalid token subject")
+	}
 
+	return userID, claims, nil
+}
+
+// CreateSignedToken menandatangani claims bebas dengan keyset (mis. state OAuth di cookie).
+// typ wajib diisi dan berbeda dari access/action token supaya tidak bisa saling dipakai.
+func CreateSignedToken(claims jwt.Claims, typ string) (string, error) {
+	ks, err := CurrentKeySet()
 	if err != nil {
-		return 0, err
+		return "", err
 	}
+	return ks.sign(claims, typ)
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if userID, ok := claims["user_id"].(float64); ok {
-			return int(userID), nil
-		}
-		return 0, errors.New("user_id not found in token")
+// ParseSignedToken memvalidasi token dari CreateSignedToken dan mengisi claims
+func ParseSignedToken(tokenStr, typ string, claims jwt.Claims) error {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return err
 	}
 
-	return 0, errors.New("invalid refresh token")
+	// token internal (tidak punya iss/aud), cukup algoritma, exp, dan iat
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc,
+		jwt.WithValidMethods(ks.algorithms()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return err
+	}
+	if t, _ := token.Header["typ"].(string); !token.Valid || t != typ {
+		return errors.New("invalid token")
+	}
+	return nil
 }
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownKeyID kid di header token tidak ada di keyset
+var ErrUnknownKeyID = errors.New("unknown signing key id")
+
+// SigningKey satu kunci di keyset. Private nil berarti kunci hanya untuk verifikasi
+// (mis. kunci lama yang sedang dirotasi keluar).
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet kumpulan kunci JWT: satu kunci aktif untuk tanda tangan, semua kunci untuk verifikasi
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+	hmac    []byte // mode lama HS256 (tanpa JWT_KEYS_DIR), tidak dipublikasikan di JWKS
+}
+
+var (
+	keySetOnce sync.Once
+	keySet     *KeySet
+	keySetErr  error
+)
+
+// LoadKeySetFromDir membaca semua file *.pem di dir. Nama file (tanpa ekstensi) dipakai
+// sebagai kid. File private key (RSA → RS256, Ed25519 → EdDSA) bisa dipakai menandatangani,
+// file public key hanya untuk verifikasi. signingKID memilih kunci aktif; jika kosong
+// dipakai private key dengan nama file terakhir secara urutan (mis. 2026-10.pem).
+//
+// Rotasi: taruh kunci baru di semua instance lebih dulu (JWKS ikut memuatnya), lalu ganti
+// JWT_SIGNING_KEY_ID; kunci lama dihapus setelah token terakhirnya kadaluarsa.
+// Contoh membuat kunci baru: openssl genpkey -algorithm ed25519 -out keys/2026-10.pem
+func LoadKeySetFromDir(dir, signingKID string) (*KeySet, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	ks := &KeySet{keys: map[string]*SigningKey{}}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		ks.keys[kid] = key
+
+		if key.Private != nil && (signingKID == "" || signingKID == kid) {
+			ks.signing = key
+		}
+	}
+
+	if ks.signing == nil {
+		if signingKID != "" {
+			return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+		}
+		return nil, fmt.Errorf("no private key found in %s", dir)
+	}
+	return ks, nil
+}
+
+// CurrentKeySet keyset global, dimuat sekali saat pertama dipakai (setelah config.Load).
+// JWT_KEYS_DIR + JWT_SIGNING_KEY_ID untuk RS256/EdDSA; tanpa JWT_KEYS_DIR kembali
+// ke HS256 dengan JWT_SECRET (hanya untuk development).
+func CurrentKeySet() (*KeySet, error) {
+	keySetOnce.Do(func() {
+		if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+			keySet, keySetErr = LoadKeySetFromDir(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+			return
+		}
+
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			keySetErr = errors.New("JWT_KEYS_DIR or JWT_SECRET must be set")
+			return
+		}
+		log.Println("JWT: JWT_KEYS_DIR not set, falling back to HS256 with JWT_SECRET")
+		keySet = &KeySet{keys: map[string]*SigningKey{}, hmac: []byte(secret)}
+	})
+	return keySet, keySetErr
+}
+
+// sign menandatangani claims dengan kunci aktif; typ diisi ke header "typ"
+func (ks *KeySet) sign(claims jwt.Claims, typ string) (string, error) {
+	if ks.signing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["typ"] = typ
+		return token.SignedString(ks.hmac)
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	token.Header["typ"] = typ
+	return token.SignedString(ks.signing.Private)
+}
+
+// keyFunc memilih kunci verifikasi berdasarkan kid dan memastikan algoritmanya cocok
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	if ks.signing == nil {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return ks.hmac, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public, nil
+}
+
+// algorithms daftar alg yang diterima saat parsing
+func (ks *KeySet) algorithms() []string {
+	if ks.signing == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	seen := map[string]bool{}
+	var algs []string
+	for _, k := range ks.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// JWK satu public key dalam format JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS daftar public key untuk /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS semua public key di keyset (kunci aktif dan kunci lama), urut berdasarkan kid
+func (ks *KeySet) JWKS() JWKS {
+	out := JWKS{Keys: []JWK{}}
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	enc := base64.RawURLEncoding
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = enc.EncodeToString(pub.N.Bytes())
+			jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = enc.EncodeToString(pub)
+		default:
+			continue
+		}
+		out.Keys = append(out.Keys, jwk)
+	}
+	return out
+}
+
+// parseKeyPEM menerima PKCS#8 / PKCS#1 private key atau PKIX public key
+func parseKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		priv interface{}
+		pub  interface{}
+		err  error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: kid}
+	if priv != nil {
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		key.Private = signer
+		pub = signer.Public()
+	}
+
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < 2048 {
+			return nil, errors.New("RSA key must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("unsupported key type (use RSA or Ed25519)")
+	}
+	key.Public = pub
+	return key, nil
+}
@@ -14,10 +14,18 @@ type APIResponse struct {
 	Data      interface{} `json:"data,omitempty"`     // payload
 	Meta      interface{} `json:"meta,omitempty"`     // pagination atau info tambahan
 	Links     interface{} `json:"links,omitempty"`    // HATEOAS links
+	Errors    []FieldError `json:"errors,omitempty"`  // error per field (validasi)
 	Timestamp string      `json:"timestamp"`          // waktu server
 	RequestID string      `json:"request_id"`         // ID unik request
 }
 
+// FieldError satu pelanggaran validasi pada field tertentu
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
 // generateRequestID membuat UUID untuk request
 func generateRequestID() string {
 	return uuid.New().String()
@@ -58,3 +66,17 @@ func Error(c *fiber.Ctx, status int, msg string, data ...interface{}) error {
 
 	return c.Status(status).JSON(resp)
 }
+
+// ValidationError mengembalikan 422 dengan daftar error per field
+func ValidationError(c *fiber.Ctx, msg string, errs []FieldError) error {
+	resp := APIResponse{
+		Status:    "error",
+		Code:      fiber.StatusUnprocessableEntity,
+		Message:   msg,
+		Errors:    errs,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		RequestID: generateRequestID(),
+	}
+
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(resp)
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// CreateRefreshToken membuat refresh token acak (opaque), bukan JWT.
+// Token hanya disimpan di database dalam bentuk hash (lihat HashToken).
+func CreateRefreshToken() (string, error) {
+	return RandomToken(32)
+}
+
+// RandomToken membuat string acak URL-safe dari n byte crypto/rand
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken mengembalikan hash SHA-256 (hex) dari token untuk disimpan di DB
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}